@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	fatihcolor "github.com/fatih/color"
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/service/cache"
+	"github.com/feiandxs/agcommits/service/openai_api"
+	"github.com/feiandxs/agcommits/service/usage"
+	"github.com/feiandxs/agcommits/utils"
+)
+
+// runCommand 执行子命令
+func runCommand(args []string) error {
+	switch args[0] {
+	case "cache":
+		return runCacheCommand(args[1:])
+	case "usage":
+		return runUsageCommand(args[1:])
+	case "models":
+		return runModelsCommand(args[1:])
+	case "prompt":
+		return runPromptCommand(args[1:])
+	default:
+		return fmt.Errorf("未知命令: %s", args[0])
+	}
+}
+
+// runCacheCommand 管理 AI 响应缓存
+func runCacheCommand(args []string) error {
+	if len(args) == 0 || args[0] != "clear" {
+		return fmt.Errorf("用法: agcommits cache clear")
+	}
+	if err := cache.Clear(); err != nil {
+		return fmt.Errorf("清除缓存失败: %w", err)
+	}
+	fatihcolor.Green("已清除 AI 响应缓存")
+	return nil
+}
+
+// runPromptCommand 输出内置的提示词模板，作为自定义 prompt_template 的起点
+func runPromptCommand(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("用法: agcommits prompt show")
+	}
+	fmt.Println(openai_api.DefaultPromptTemplate)
+	return nil
+}
+
+// runUsageCommand 按模型或仓库汇总 AI 调用的 token 用量和费用
+func runUsageCommand(args []string) error {
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	since := flags.String("since", "30d", "统计的时间范围，如 7d、2w、12h")
+	by := flags.String("by", "model", "汇总维度：model 或 repo")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	period, err := usage.ParseSince(*since)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	records, err := usage.Load(time.Now().Add(-period))
+	if err != nil {
+		return fmt.Errorf("读取用量记录失败: %w", err)
+	}
+	summaries, err := usage.Summarize(records, *by, cfg.ModelPrices)
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		fatihcolor.Yellow("最近 %s 内没有 AI 调用记录", *since)
+		return nil
+	}
+
+	currency := cfg.PriceCurrency
+	if currency == "" {
+		currency = "USD"
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(writer, "%s\tcalls\tprompt tokens\tcompletion tokens\tcost (%s)\n", *by, currency)
+	var total usage.Summary
+	unpriced := false
+	for _, summary := range summaries {
+		cost := fmt.Sprintf("%.4f", summary.Cost)
+		if summary.Unpriced {
+			cost += " *"
+			unpriced = true
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%s\n",
+			summary.Key, summary.Calls, summary.PromptTokens, summary.CompletionTokens, cost)
+		total.Calls += summary.Calls
+		total.PromptTokens += summary.PromptTokens
+		total.CompletionTokens += summary.CompletionTokens
+		total.Cost += summary.Cost
+	}
+	fmt.Fprintf(writer, "total\t%d\t%d\t%d\t%.4f\n",
+		total.Calls, total.PromptTokens, total.CompletionTokens, total.Cost)
+	writer.Flush()
+
+	if unpriced {
+		fmt.Println("* 部分模型未在 model_prices 中配置价格，费用按 0 计算")
+	}
+	return nil
+}
+
+// runModelsCommand 从服务商获取可用的对话模型，交互选择后保存到 openai_model
+func runModelsCommand(args []string) error {
+	flags := flag.NewFlagSet("models", flag.ContinueOnError)
+	list := flags.Bool("list", false, "只列出可用模型，不进行选择")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("加载配置文件失败: %w", err)
+	}
+	if *list {
+		models, err := listChatModels(cfg)
+		if err != nil {
+			return err
+		}
+		for _, model := range models {
+			fmt.Println(model)
+		}
+		return nil
+	}
+
+	model, err := selectModel(cfg)
+	if err != nil {
+		return err
+	}
+	if err := config.UpdateConfigField("openai_model", model); err != nil {
+		return fmt.Errorf("更新配置失败: %w", err)
+	}
+	fatihcolor.Green("已将 openai_model 设置为 %s", model)
+	return nil
+}
+
+// listChatModels 获取服务商提供的对话模型，请求期间可用 Ctrl-C 中断
+func listChatModels(cfg *config.Config) ([]string, error) {
+	warnInsecureTLS(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	models, err := openai_api.ListChatModels(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("服务商未返回可用的对话模型")
+	}
+	return models, nil
+}
+
+// selectModel 获取模型列表并让用户交互选择
+func selectModel(cfg *config.Config) (string, error) {
+	fatihcolor.Yellow("正在获取可用模型列表...")
+	models, err := listChatModels(cfg)
+	if err != nil {
+		return "", err
+	}
+	return utils.SelectModel(models, cfg.OpenAIModel)
+}
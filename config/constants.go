@@ -4,8 +4,29 @@ import "errors"
 
 const (
 	ConfigFileName = ".agcommitsrc.yaml"
+
+	// ProjectConfigFileName 仓库根目录下的项目配置文件
+	ProjectConfigFileName = ".agcommits.yaml"
 )
 
+// projectAllowedFields 项目配置中允许设置的字段，只包含提交信息风格相关的配置
+// 其余字段（密钥、API 地址、模型、自动提交等）只能在全局配置中设置，避免克隆的仓库改变 agcommits 的行为
+var projectAllowedFields = map[string]bool{
+	"commit_type":        true,
+	"commit_locale":      true,
+	"max_length":         true,
+	"subject_width":      true,
+	"body":               true,
+	"breaking_detection": true,
+	"types":              true,
+	"scopes":             true,
+	"scope_map":          true,
+	"tickets":            true,
+	"history":            true,
+	"context":            true,
+	"prompt_template":    true,
+}
+
 var (
 	ErrConfigNotFound     = errors.New("配置文件不存在")
 	ErrConfigInvalid      = errors.New("配置文件格式不正确")
@@ -5,7 +5,10 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/feiandxs/agcommits/constants"
 	"gopkg.in/yaml.v3"
 )
 
@@ -79,6 +82,90 @@ func LoadConfig() (*Config, error) {
 	return config, nil
 }
 
+// ApplyProjectConfig 读取仓库根目录下的项目配置并覆盖全局配置中的同名字段
+// 只接受 projectAllowedFields 中的风格相关字段，其余字段会被忽略；模板文件必须位于仓库内
+// 返回被忽略的字段名；不在 Git 仓库中或没有项目配置时不做任何修改
+func ApplyProjectConfig(config *Config, repoRoot string) ([]string, error) {
+	if repoRoot == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(repoRoot, ProjectConfigFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrConfigInvalid, ProjectConfigFileName)
+	}
+	var ignored []string
+	for name := range fields {
+		if !projectAllowedFields[name] {
+			ignored = append(ignored, name)
+			delete(fields, name)
+		}
+	}
+	sort.Strings(ignored)
+	content, err := yaml.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrConfigInvalid, ProjectConfigFileName)
+	}
+	// 项目配置中的模板文件路径相对于仓库根目录，且不能指向仓库以外的文件
+	if _, ok := fields["prompt_template"]; ok && IsTemplatePath(config.PromptTemplate) {
+		path, err := projectTemplatePath(repoRoot, config.PromptTemplate)
+		if err != nil {
+			return nil, err
+		}
+		config.PromptTemplate = path
+	}
+	return ignored, nil
+}
+
+// IsTemplatePath 判断 prompt_template 的值是否为模板文件路径而不是模板文本
+// 单行、不含模板动作，且含有路径分隔符或以 .tmpl、.tpl、.txt、.md 结尾时视为路径
+func IsTemplatePath(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.Contains(value, "\n") || strings.Contains(value, "{{") {
+		return false
+	}
+	if strings.Contains(value, "/") || strings.Contains(value, `\`) {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(value)) {
+	case ".tmpl", ".tpl", ".txt", ".md":
+		return true
+	}
+	return false
+}
+
+// projectTemplatePath 将项目配置中的模板路径解析为仓库内的绝对路径，路径位于仓库以外时返回错误
+func projectTemplatePath(repoRoot, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if filepath.IsAbs(value) || strings.HasPrefix(value, "~") {
+		return "", fmt.Errorf("%w: %s 中的 prompt_template 必须是仓库内的相对路径", ErrConfigInvalid, ProjectConfigFileName)
+	}
+	root := repoRoot
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		root = resolved
+	}
+	path := filepath.Join(root, value)
+	// 解析符号链接，避免通过仓库内的链接读取仓库以外的文件
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s 中的 prompt_template 必须是仓库内的相对路径", ErrConfigInvalid, ProjectConfigFileName)
+	}
+	return path, nil
+}
+
 // SaveConfig 保存配置到文件
 func SaveConfig(config *Config) error {
 	configPath, err := GetConfigFilePath()
@@ -115,7 +202,56 @@ func UpdateConfigField(fieldName, value string) error {
 	case "commit_locale":
 		config.CommitLocale = value
 	case "commit_type":
-		config.CommitType = value
+		switch value {
+		case constants.ConventionalCommitType, constants.FreeFormCommitType, constants.GitmojiCommitType,
+			constants.AngularCommitType, constants.KernelCommitType:
+			config.CommitType = value
+		default:
+			return fmt.Errorf("invalid commit_type value: %s (should be conventional, default, gitmoji, angular or kernel)", value)
+		}
+	case "body":
+		switch value {
+		case constants.BodyOff, constants.BodyAuto, constants.BodyAlways:
+			config.Body = value
+		default:
+			return fmt.Errorf("invalid body value: %s (should be off, auto or always)", value)
+		}
+	case "breaking_detection":
+		switch value {
+		case constants.BreakingDetectionOff, constants.BreakingDetectionPrompt, constants.BreakingDetectionEnforce:
+			config.BreakingDetection = value
+		default:
+			return fmt.Errorf("invalid breaking_detection value: %s (should be off, prompt or enforce)", value)
+		}
+	case "subject_width":
+		var width int
+		if _, err := fmt.Sscanf(value, "%d", &width); err != nil || width < 0 {
+			return fmt.Errorf("invalid subject_width value: %s", value)
+		}
+		config.SubjectWidth = width
+	case "output_format":
+		switch value {
+		case constants.OutputFormatText, constants.OutputFormatJSON, constants.OutputFormatJSONObject:
+			config.OutputFormat = value
+		default:
+			return fmt.Errorf("invalid output_format value: %s (should be text, json or json_object)", value)
+		}
+	case "prompt_template":
+		config.PromptTemplate = value
+	case "merge_system_prompt":
+		if value == "true" {
+			config.MergeSystemPrompt = true
+		} else if value == "false" {
+			config.MergeSystemPrompt = false
+		} else {
+			return fmt.Errorf("invalid merge_system_prompt value: %s (should be true or false)", value)
+		}
+	case "candidates":
+		var candidates int
+		if _, err := fmt.Sscanf(value, "%d", &candidates); err != nil || candidates < 1 {
+			return fmt.Errorf("invalid candidates value: %s (should be at least 1)", value)
+		}
+		config.Candidates = candidates
 	case "max_length":
 		// 需要转换为int
 		var length int
@@ -123,6 +259,63 @@ func UpdateConfigField(fieldName, value string) error {
 			return fmt.Errorf("invalid max_length value: %s", value)
 		}
 		config.MaxLength = length
+	case "max_tokens":
+		var tokens int
+		if _, err := fmt.Sscanf(value, "%d", &tokens); err != nil || tokens < 0 {
+			return fmt.Errorf("invalid max_tokens value: %s", value)
+		}
+		config.MaxTokens = tokens
+	case "temperature":
+		var temperature float64
+		if _, err := fmt.Sscanf(value, "%g", &temperature); err != nil || temperature < 0 || temperature > 2 {
+			return fmt.Errorf("invalid temperature value: %s (should be between 0 and 2)", value)
+		}
+		config.Temperature = &temperature
+	case "top_p":
+		var topP float64
+		if _, err := fmt.Sscanf(value, "%g", &topP); err != nil || topP <= 0 || topP > 1 {
+			return fmt.Errorf("invalid top_p value: %s (should be between 0 and 1)", value)
+		}
+		config.TopP = &topP
+	case "reasoning_model":
+		if value == "true" || value == "false" {
+			reasoning := value == "true"
+			config.ReasoningModel = &reasoning
+		} else {
+			return fmt.Errorf("invalid reasoning_model value: %s (should be true or false)", value)
+		}
+	case "seed":
+		var seed int
+		if _, err := fmt.Sscanf(value, "%d", &seed); err != nil {
+			return fmt.Errorf("invalid seed value: %s", value)
+		}
+		config.Seed = &seed
+	case "http_proxy":
+		config.HTTPProxy = value
+	case "ca_bundle":
+		config.CABundle = value
+	case "insecure_skip_verify":
+		if value == "true" {
+			config.InsecureSkipVerify = true
+		} else if value == "false" {
+			config.InsecureSkipVerify = false
+		} else {
+			return fmt.Errorf("invalid insecure_skip_verify value: %s (should be true or false)", value)
+		}
+	case "generator":
+		switch value {
+		case constants.GeneratorAI, constants.GeneratorOffline:
+			config.Generator = value
+		default:
+			return fmt.Errorf("invalid generator value: %s (should be ai or offline)", value)
+		}
+	case "offline_fallback":
+		if value == "true" || value == "false" {
+			fallback := value == "true"
+			config.OfflineFallback = &fallback
+		} else {
+			return fmt.Errorf("invalid offline_fallback value: %s (should be true or false)", value)
+		}
 	case "auto_add":
 		// 需要转换为bool
 		if value == "true" {
@@ -141,6 +334,31 @@ func UpdateConfigField(fieldName, value string) error {
 		} else {
 			return fmt.Errorf("invalid auto_commit value: %s (should be true or false)", value)
 		}
+	case "request_timeout":
+		// 需要转换为int
+		var timeout int
+		if _, err := fmt.Sscanf(value, "%d", &timeout); err != nil || timeout < 0 {
+			return fmt.Errorf("invalid request_timeout value: %s", value)
+		}
+		config.RequestTimeout = timeout
+	case "cache_ttl":
+		var ttl int
+		if _, err := fmt.Sscanf(value, "%d", &ttl); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid cache_ttl value: %s", value)
+		}
+		config.CacheTTL = ttl
+	case "cache_max_entries":
+		var maxEntries int
+		if _, err := fmt.Sscanf(value, "%d", &maxEntries); err != nil || maxEntries < 0 {
+			return fmt.Errorf("invalid cache_max_entries value: %s", value)
+		}
+		config.CacheMaxEntries = maxEntries
+	case "context_window":
+		var window int
+		if _, err := fmt.Sscanf(value, "%d", &window); err != nil || window < 0 {
+			return fmt.Errorf("invalid context_window value: %s", value)
+		}
+		config.ContextWindow = window
 	default:
 		return fmt.Errorf("unknown field: %s", fieldName)
 	}
@@ -154,14 +372,47 @@ func ListConfigFields() (map[string]interface{}, error) {
 		return nil, err
 	}
 	return map[string]interface{}{
-		"openai_key":      config.OpenAIKey,
-		"openai_api_base": config.OpenAPIBase,
-		"openai_model":    config.OpenAIModel,
-		"commit_locale":   config.CommitLocale,
-		"max_length":      config.MaxLength,
-		"commit_type":     config.CommitType,
-		"auto_add":        config.AutoAdd,
-		"auto_commit":     config.AutoCommit,
+		"openai_key":           config.OpenAIKey,
+		"openai_api_base":      config.OpenAPIBase,
+		"openai_model":         config.OpenAIModel,
+		"commit_locale":        config.CommitLocale,
+		"max_length":           config.MaxLength,
+		"max_tokens":           config.MaxTokens,
+		"temperature":          config.Temperature,
+		"top_p":                config.TopP,
+		"seed":                 config.Seed,
+		"reasoning_model":      config.ReasoningModel,
+		"stop":                 config.Stop,
+		"extra_body":           config.ExtraBody,
+		"http_proxy":           config.HTTPProxy,
+		"ca_bundle":            config.CABundle,
+		"insecure_skip_verify": config.InsecureSkipVerify,
+		"extra_headers":        config.ExtraHeaders,
+		"commit_type":          config.CommitType,
+		"types":                config.Types,
+		"scopes":               config.Scopes,
+		"scope_map":            config.ScopeMap,
+		"tickets":              config.Tickets,
+		"history":              config.History,
+		"context":              config.Context,
+		"body":                 config.Body,
+		"breaking_detection":   config.BreakingDetection,
+		"subject_width":        config.SubjectWidth,
+		"output_format":        config.OutputFormat,
+		"candidates":           config.Candidates,
+		"prompt_template":      config.PromptTemplate,
+		"merge_system_prompt":  config.MergeSystemPrompt,
+		"generator":            config.Generator,
+		"offline_fallback":     config.OfflineFallback,
+		"auto_add":             config.AutoAdd,
+		"auto_commit":          config.AutoCommit,
+		"request_timeout":      config.RequestTimeout,
+		"cache_ttl":            config.CacheTTL,
+		"cache_max_entries":    config.CacheMaxEntries,
+		"context_window":       config.ContextWindow,
+		"model_prices":         config.ModelPrices,
+		"price_currency":       config.PriceCurrency,
+		"budget":               config.Budget,
 	}, nil
 }
 
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"github.com/feiandxs/agcommits/constants"
+	"gopkg.in/yaml.v3"
+)
+
 // Config 应用程序配置结构体
 type Config struct {
 	// OpenAI API 密钥，用于调用 AI 服务生成提交消息
@@ -17,26 +22,226 @@ type Config struct {
 	// 提交消息的最大字符长度限制
 	MaxLength int `yaml:"max_length"`
 
+	// AI 响应的最大 token 数，与 max_length 无关，0 表示使用默认值
+	MaxTokens int `yaml:"max_tokens"`
+
+	// 采样温度，未配置时使用服务商默认值；设为 0 并固定 seed 可获得可复现的结果
+	Temperature *float64 `yaml:"temperature,omitempty"`
+
+	// 核采样概率，未配置时使用服务商默认值
+	TopP *float64 `yaml:"top_p,omitempty"`
+
+	// 是否按推理模型（如 OpenAI o 系列）调整请求：使用 max_completion_tokens，不发送 temperature 和 top_p
+	// 未配置时根据模型名称自动判断
+	ReasoningModel *bool `yaml:"reasoning_model,omitempty"`
+
+	// 随机种子，未配置时不发送
+	Seed *int `yaml:"seed,omitempty"`
+
+	// 停止序列，生成内容遇到其中任一序列即停止
+	Stop []string `yaml:"stop,omitempty"`
+
+	// 写入请求体的额外字段，用于服务商特有的参数，同名时覆盖内置参数
+	ExtraBody map[string]interface{} `yaml:"extra_body,omitempty"`
+
+	// 访问 AI 服务使用的 HTTP 代理地址，为空时使用环境变量 HTTP_PROXY/HTTPS_PROXY
+	HTTPProxy string `yaml:"http_proxy,omitempty"`
+
+	// 额外信任的 CA 证书文件（PEM 格式），用于企业内部网关
+	CABundle string `yaml:"ca_bundle,omitempty"`
+
+	// 跳过 TLS 证书校验，存在中间人攻击风险，仅限排查问题时临时使用
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+
+	// 每个请求附加的 HTTP 请求头，如网关要求的 X-Team-Id
+	ExtraHeaders map[string]string `yaml:"extra_headers,omitempty"`
+
 	// 提交消息格式类型：conventional（约定式提交）或 default（默认格式）
 	CommitType string `yaml:"commit_type"`
 
+	// 项目自定义的提交类型，配置后替换内置的类型列表
+	Types []CommitTypeDef `yaml:"types,omitempty"`
+
+	// 允许使用的 scope 列表，为空时不限制
+	Scopes []string `yaml:"scopes,omitempty"`
+
+	// 路径规则到 scope 的映射，如 "web/**": web；未匹配的文件使用 Go 包名或顶层目录
+	ScopeMap map[string]string `yaml:"scope_map,omitempty"`
+
+	// 提交消息正文：off（只有标题，默认）、auto（需要时生成正文）或 always（总是生成正文）
+	Body string `yaml:"body,omitempty"`
+
+	// 启用正文时标题的最大长度，0 表示使用默认值 72（不超过 max_length）
+	SubjectWidth int `yaml:"subject_width,omitempty"`
+
+	// 破坏性变更检测：enforce（强制加上标记和脚注，默认）、prompt（只告知模型）或 off（不检测）
+	BreakingDetection string `yaml:"breaking_detection,omitempty"`
+
+	// 从分支名中提取工单编号并写入提交消息
+	Tickets Tickets `yaml:"tickets,omitempty"`
+
+	// 从仓库历史中选取提交标题作为风格示例
+	History History `yaml:"history,omitempty"`
+
+	// 写入提示词的仓库上下文，各项默认关闭
+	Context PromptContext `yaml:"context,omitempty"`
+
+	// 模型输出格式：text（自由文本）、json（JSON Schema 结构化输出）或 json_object
+	OutputFormat string `yaml:"output_format,omitempty"`
+
+	// 自定义提示词模板（text/template 语法），可以是模板文本或模板文件路径，为空时使用内置模板
+	PromptTemplate string `yaml:"prompt_template,omitempty"`
+
+	// 将系统提示合并到用户消息中发送，用于不支持 system 角色的模型；只作用于主模型，不作用于 budget.fallback_model
+	MergeSystemPrompt bool `yaml:"merge_system_prompt,omitempty"`
+
+	// 每次生成的候选提交消息数量，大于 1 时以列表形式供用户选择
+	Candidates int `yaml:"candidates,omitempty"`
+
+	// 提交消息生成方式：ai（调用 AI 服务，默认）或 offline（按文件变更规则生成，无需网络）
+	Generator string `yaml:"generator,omitempty"`
+
+	// AI 生成失败时是否退回规则生成，未配置时默认启用
+	OfflineFallback *bool `yaml:"offline_fallback,omitempty"`
+
 	// 是否自动执行 git add 命令，跳过用户确认（true：自动执行，false：需要确认）
 	AutoAdd bool `yaml:"auto_add"`
 
 	// 是否自动执行 git commit 命令，跳过用户确认（true：自动提交，false：需要确认）
 	AutoCommit bool `yaml:"auto_commit"`
+
+	// AI 请求超时时间（秒），0 表示使用默认值
+	RequestTimeout int `yaml:"request_timeout"`
+
+	// AI 响应缓存有效期（分钟），0 表示使用默认值
+	CacheTTL int `yaml:"cache_ttl"`
+
+	// AI 响应缓存最多保留的记录数，0 表示使用默认值
+	CacheMaxEntries int `yaml:"cache_max_entries"`
+
+	// 模型上下文窗口大小（tokens），0 表示按模型名称自动推断
+	ContextWindow int `yaml:"context_window"`
+
+	// 模型价格表，键为模型名称，用于统计 AI 调用费用
+	ModelPrices map[string]ModelPrice `yaml:"model_prices,omitempty"`
+
+	// 价格表使用的货币单位，如 USD、CNY
+	PriceCurrency string `yaml:"price_currency,omitempty"`
+
+	// 用量预算，超出后拒绝调用或切换到备用模型
+	Budget Budget `yaml:"budget,omitempty"`
+}
+
+// Budget 当前配置的 AI 服务（openai_api_base）的每日/每月用量上限，0 表示不限制
+type Budget struct {
+	// 每日 token 上限（输入与输出合计）
+	DailyTokens int `yaml:"daily_tokens,omitempty"`
+
+	// 每月 token 上限（输入与输出合计）
+	MonthlyTokens int `yaml:"monthly_tokens,omitempty"`
+
+	// 每日费用上限，按 model_prices 计算
+	DailyCost float64 `yaml:"daily_cost,omitempty"`
+
+	// 每月费用上限，按 model_prices 计算
+	MonthlyCost float64 `yaml:"monthly_cost,omitempty"`
+
+	// 用量达到上限的该比例时发出警告，0 表示使用默认值 0.8
+	WarnRatio float64 `yaml:"warn_ratio,omitempty"`
+
+	// 超出预算后改用的备用模型（如本地模型），为空时直接拒绝调用
+	FallbackModel string `yaml:"fallback_model,omitempty"`
+
+	// 备用模型的 API 基础 URL，为空时沿用 openai_api_base
+	FallbackAPIBase string `yaml:"fallback_api_base,omitempty"`
+
+	// 备用模型的 API 密钥，为空时沿用 openai_key
+	FallbackKey string `yaml:"fallback_key,omitempty"`
+}
+
+// History 风格示例配置，让生成的提交消息模仿仓库已有的写法
+type History struct {
+	// 作为示例的最近非合并提交数量，0 表示不使用示例
+	Examples int `yaml:"examples,omitempty"`
+
+	// 只选取匹配该作者的提交（同 git log --author），me 表示当前 git 用户
+	Author string `yaml:"author,omitempty"`
+
+	// 只选取改动了这些路径（相对于仓库根目录）的提交，staged 表示暂存区中的文件
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// PromptContext 附加到提示词中的仓库上下文开关，超出上下文窗口时按 readme、相关提交、文件列表、分支的顺序裁剪
+type PromptContext struct {
+	// 写入当前分支名
+	Branch bool `yaml:"branch,omitempty"`
+
+	// 写入暂存区文件列表及状态（A/M/D/R 等）
+	Files bool `yaml:"files,omitempty"`
+
+	// 写入最近改动过相同文件的提交标题
+	RelatedCommits bool `yaml:"related_commits,omitempty"`
+
+	// 写入从仓库 README 中提取的项目简介
+	Readme bool `yaml:"readme,omitempty"`
+}
+
+// Tickets 工单编号配置，未配置 patterns 时不提取
+type Tickets struct {
+	// 提取工单编号的正则表达式，含有分组时取第一个分组
+	Patterns []string `yaml:"patterns,omitempty"`
+
+	// 工单编号的位置：footer（脚注，默认）或 subject（标题前缀）
+	Position string `yaml:"position,omitempty"`
+
+	// 渲染工单编号的模板（text/template 语法），可用 .ID、.IDs、.Branch 和 join 函数
+	Template string `yaml:"template,omitempty"`
+}
+
+// CommitTypeDef 自定义提交类型，也可以只写类型名称
+type CommitTypeDef struct {
+	// 类型名称，如 deps
+	Name string `yaml:"name"`
+
+	// 类型说明，写入提示词帮助模型选择类型
+	Description string `yaml:"description,omitempty"`
+}
+
+// UnmarshalYAML 支持 "- deps" 和 "- {name: deps, description: ...}" 两种写法
+func (t *CommitTypeDef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		t.Name = value.Value
+		return nil
+	}
+	type plain CommitTypeDef
+	return value.Decode((*plain)(t))
+}
+
+// ModelPrice 模型价格，单位为每百万 tokens 的费用
+type ModelPrice struct {
+	// 输入（提示词）价格
+	Input float64 `yaml:"input"`
+
+	// 输出（生成内容）价格
+	Output float64 `yaml:"output"`
 }
 
 // NewDefaultConfig returns default configuration
 func NewDefaultConfig() *Config {
 	return &Config{
-		OpenAIKey:    "",
-		OpenAPIBase:  "",
-		OpenAIModel:  "",
-		CommitLocale: "zh",
-		MaxLength:    150,
-		CommitType:   "conventional",
-		AutoAdd:      false, // 默认需要确认
-		AutoCommit:   false, // 默认需要确认
+		OpenAIKey:       "",
+		OpenAPIBase:     "",
+		OpenAIModel:     "",
+		CommitLocale:    "zh",
+		MaxLength:       150,
+		MaxTokens:       constants.DefaultMaxTokens,
+		CommitType:      "conventional",
+		OutputFormat:    constants.OutputFormatText,
+		AutoAdd:         false, // 默认需要确认
+		AutoCommit:      false, // 默认需要确认
+		RequestTimeout:  constants.DefaultRequestTimeout,
+		CacheTTL:        constants.DefaultCacheTTL,
+		CacheMaxEntries: constants.DefaultCacheMaxEntries,
+		History:         History{Examples: constants.DefaultHistoryExamples},
 	}
 }
@@ -52,4 +52,10 @@ var ConfigFields = []ConfigField{
 		Placeholder: "false",
 		Help:        "是否自动执行git commit命令，跳过确认步骤(true/false)",
 	},
+	{
+		Name:        "request_timeout",
+		Required:    false,
+		Placeholder: "60",
+		Help:        "AI请求超时时间(秒)",
+	},
 }
@@ -10,6 +10,42 @@ const (
 
 	// DefaultMaxLength 提交消息的默认最大长度
 	DefaultMaxLength = 150
+
+	// DefaultSubjectWidth 启用正文时提交消息标题的默认最大长度
+	DefaultSubjectWidth = 72
+
+	// BodyWrapWidth 提交消息正文的换行宽度
+	BodyWrapWidth = 72
+
+	// DefaultMaxTokens AI 响应的默认最大 token 数，需为推理模型的思考过程留出余量
+	DefaultMaxTokens = 1024
+
+	// DefaultRequestTimeout AI 请求的默认超时时间（秒）
+	DefaultRequestTimeout = 60
+
+	// DefaultCacheTTL AI 响应缓存的默认有效期（分钟）
+	DefaultCacheTTL = 1440
+
+	// DefaultCacheMaxEntries AI 响应缓存默认最多保留的记录数
+	DefaultCacheMaxEntries = 200
+
+	// DefaultHistoryExamples 新建配置时作为风格示例的历史提交数量
+	DefaultHistoryExamples = 10
+
+	// DefaultRegenerateAttempts 提交消息不符合格式时最多重新生成的次数
+	DefaultRegenerateAttempts = 2
+
+	// DefaultRecentCommits 提示词模板中可用的最近提交数量
+	DefaultRecentCommits = 10
+
+	// DefaultRelatedCommits 仓库上下文中改动过相同文件的提交数量
+	DefaultRelatedCommits = 5
+
+	// DefaultReadmeLength 仓库上下文中项目简介的最大字符数
+	DefaultReadmeLength = 500
+
+	// DefaultBudgetWarnRatio 用量达到预算上限的该比例时发出警告
+	DefaultBudgetWarnRatio = 0.8
 )
 
 // 提交类型相关默认值
@@ -19,4 +55,79 @@ const (
 
 	// ConventionalCommitType Conventional Commits规范的提交类型
 	ConventionalCommitType = "conventional"
+
+	// FreeFormCommitType 自由格式，不要求类型前缀
+	FreeFormCommitType = "default"
+
+	// GitmojiCommitType 以 gitmoji 表情开头的提交格式
+	GitmojiCommitType = "gitmoji"
+
+	// AngularCommitType Angular 规范的提交格式，必须带 scope
+	AngularCommitType = "angular"
+
+	// KernelCommitType Linux 内核风格的 "子系统: 概要" 格式
+	KernelCommitType = "kernel"
+)
+
+// 模型输出格式
+const (
+	// OutputFormatText 自由文本，模型直接返回提交消息
+	OutputFormatText = "text"
+
+	// OutputFormatJSON 结构化 JSON，使用 JSON Schema 约束模型输出
+	OutputFormatJSON = "json"
+
+	// OutputFormatJSONObject 结构化 JSON，用于仅支持 json_object 的服务商
+	OutputFormatJSONObject = "json_object"
+)
+
+// 破坏性变更检测模式
+const (
+	// BreakingDetectionOff 不检测破坏性变更
+	BreakingDetectionOff = "off"
+
+	// BreakingDetectionPrompt 只在提示词中告知模型检测结果，由模型判断是否标记
+	BreakingDetectionPrompt = "prompt"
+
+	// BreakingDetectionEnforce 检测到破坏性变更时强制加上标记和脚注（默认）
+	BreakingDetectionEnforce = "enforce"
+)
+
+// 提交消息正文模式
+const (
+	// BodyOff 只生成标题，不生成正文
+	BodyOff = "off"
+
+	// BodyAuto 由模型判断是否需要正文
+	BodyAuto = "auto"
+
+	// BodyAlways 总是生成解释改动原因的正文
+	BodyAlways = "always"
+)
+
+// 工单编号在提交消息中的位置
+const (
+	// TicketPositionFooter 作为脚注添加到提交消息末尾
+	TicketPositionFooter = "footer"
+
+	// TicketPositionSubject 加在标题描述之前
+	TicketPositionSubject = "subject"
+)
+
+// 提交消息生成方式
+const (
+	// GeneratorAI 调用 AI 服务生成提交消息
+	GeneratorAI = "ai"
+
+	// GeneratorOffline 根据暂存区文件变更按规则生成，无需网络和 API 密钥
+	GeneratorOffline = "offline"
+)
+
+// 进程退出码
+const (
+	// ExitCodeTimeout AI 请求超时时的退出码
+	ExitCodeTimeout = 124
+
+	// ExitCodeCanceled 用户中断（Ctrl-C）时的退出码
+	ExitCodeCanceled = 130
 )
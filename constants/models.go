@@ -0,0 +1,63 @@
+package constants
+
+import "strings"
+
+// DefaultContextWindow 未知模型的默认上下文窗口大小（tokens），按较保守的值估计
+const DefaultContextWindow = 8192
+
+// modelContextWindow 模型名称前缀与上下文窗口大小的对应关系
+type modelContextWindow struct {
+	Prefix string // 模型名称前缀（小写，不含服务商路径）
+	Tokens int    // 上下文窗口大小
+}
+
+// modelContextWindows 常见模型的上下文窗口，按前缀匹配，越具体的前缀越靠前
+var modelContextWindows = []modelContextWindow{
+	// OpenAI
+	{Prefix: "gpt-4.1", Tokens: 1047576},
+	{Prefix: "gpt-4o", Tokens: 128000},
+	{Prefix: "gpt-4-turbo", Tokens: 128000},
+	{Prefix: "gpt-4-1106", Tokens: 128000},
+	{Prefix: "gpt-4-0125", Tokens: 128000},
+	{Prefix: "gpt-4-32k", Tokens: 32768},
+	{Prefix: "gpt-4", Tokens: 8192},
+	{Prefix: "gpt-3.5-turbo-instruct", Tokens: 4096},
+	{Prefix: "gpt-3.5-turbo", Tokens: 16385},
+	{Prefix: "gpt-5", Tokens: 400000},
+	{Prefix: "o1-mini", Tokens: 128000},
+	{Prefix: "o1", Tokens: 200000},
+	{Prefix: "o3", Tokens: 200000},
+	{Prefix: "o4", Tokens: 200000},
+	// Qwen
+	{Prefix: "qwen2.5-coder", Tokens: 32768},
+	{Prefix: "qwen2.5", Tokens: 32768},
+	{Prefix: "qwen3", Tokens: 32768},
+	{Prefix: "qwq", Tokens: 32768},
+	// DeepSeek
+	{Prefix: "deepseek", Tokens: 65536},
+	// GLM
+	{Prefix: "glm-4", Tokens: 128000},
+	// Llama
+	{Prefix: "llama-3", Tokens: 8192},
+	{Prefix: "meta-llama-3.1", Tokens: 128000},
+	{Prefix: "meta-llama-3", Tokens: 8192},
+}
+
+// NormalizeModelName 去掉服务商路径前缀并转换为小写，如 Qwen/Qwen2.5-Coder-7B-Instruct -> qwen2.5-coder-7b-instruct
+func NormalizeModelName(model string) string {
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	return strings.ToLower(model)
+}
+
+// GetModelContextWindow 返回模型的上下文窗口大小，未知模型返回 DefaultContextWindow
+func GetModelContextWindow(model string) int {
+	name := NormalizeModelName(model)
+	for _, window := range modelContextWindows {
+		if strings.HasPrefix(name, window.Prefix) {
+			return window.Tokens
+		}
+	}
+	return DefaultContextWindow
+}
@@ -1,17 +1,42 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	fatihcolor "github.com/fatih/color"
 	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+	"github.com/feiandxs/agcommits/service/heuristic"
 	"github.com/feiandxs/agcommits/service/openai_api"
+	"github.com/feiandxs/agcommits/service/ticket"
+	"github.com/feiandxs/agcommits/service/usage"
 	"github.com/feiandxs/agcommits/utils"
 	"github.com/shibukawa/cdiff"
 )
 
 func main() {
+	noCache := flag.Bool("no-cache", false, "跳过 AI 响应缓存，强制重新生成提交消息")
+	offline := flag.Bool("offline", false, "不调用 AI，根据暂存区的文件变更按规则生成提交消息")
+	hint := flag.String("m", "", "说明本次改动的意图，作为参考写入提示词，引导 AI 生成提交消息")
+	flag.Parse()
+
+	// 子命令，如 agcommits cache clear
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			fatihcolor.Red("%v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// if config file not exists, create it
 	exists, err := config.IsConfigFileExists()
 	if err != nil {
@@ -34,36 +59,51 @@ func main() {
 		fatihcolor.Red("加载配置文件失败: %v", err)
 		return
 	}
+	// 仓库根目录下的项目配置覆盖全局配置
+	repoRoot, _ := utils.GetRepoRoot()
+	ignored, err := config.ApplyProjectConfig(cfg, repoRoot)
+	if err != nil {
+		fatihcolor.Red("加载项目配置失败: %v", err)
+		return
+	}
+	if len(ignored) > 0 {
+		fatihcolor.Yellow("项目配置 %s 中的 %s 只能在全局配置中设置，已忽略", config.ProjectConfigFileName, strings.Join(ignored, ", "))
+	}
 
-	// 检查并补充缺失的必填项
-	changed := false
-	for _, field := range config.ConfigFields {
-		value := utils.GetConfigValue(cfg, field.Name)
-		if (field.Required && value == "") || (!field.Required && value == "" && utils.AskForOptional(field)) {
-			newValue, err := utils.PromptForValue(field)
-			if err != nil {
-				fatihcolor.Red("获取输入失败: %v", err)
-				return
-			}
-			if newValue != "" {
-				if err := config.UpdateConfigField(field.Name, newValue); err != nil {
-					fatihcolor.Red("更新配置失败: %v", err)
+	// 规则生成不需要 AI 服务，跳过 API 密钥等必填项的检查
+	useOffline := *offline || cfg.Generator == constants.GeneratorOffline
+	if !useOffline {
+		// 检查并补充缺失的必填项
+		changed := false
+		for _, field := range config.ConfigFields {
+			value := utils.GetConfigValue(cfg, field.Name)
+			if (field.Required && value == "") || (!field.Required && value == "" && utils.AskForOptional(field)) {
+				newValue, err := promptForField(field)
+				if err != nil {
+					fatihcolor.Red("获取输入失败: %v", err)
 					return
 				}
-				changed = true
+				if newValue != "" {
+					if err := config.UpdateConfigField(field.Name, newValue); err != nil {
+						fatihcolor.Red("更新配置失败: %v", err)
+						return
+					}
+					changed = true
+				}
 			}
 		}
-	}
-	if changed {
-		fatihcolor.Green("配置已更新")
-	}
-	// 最终验证
-	if err := config.CheckConfig(); err != nil {
-		fatihcolor.Red("配置验证失败: %v", err)
-		return
-	}
+		if changed {
+			fatihcolor.Green("配置已更新")
+		}
+		// 最终验证
+		if err := config.CheckConfig(); err != nil {
+			fatihcolor.Red("配置验证失败: %v", err)
+			return
+		}
 
-	fatihcolor.Green("配置验证通过")
+		fatihcolor.Green("配置验证通过")
+		warnInsecureTLS(cfg)
+	}
 
 	// 检查是否在 Git 仓库中
 	isRepo, err := utils.IsGitRepository()
@@ -138,27 +178,67 @@ func main() {
 		// 使用String方法直接获取格式化后的字符串，然后手动添加颜色
 		diffText := diffResult.String()
 		printColoredDiff(diffText)
-		fmt.Println("===================================\n")
+		fmt.Println("===================================")
+		fmt.Println()
 	}
 
-	// 使用 OpenAI API 生成提交消息
-	fatihcolor.Yellow("正在使用 AI 生成提交消息...")
-	commitMsg, err := openai_api.GenerateCommitMessage(cfg, diff)
-	if err != nil {
-		fatihcolor.Red("生成提交消息失败: %v", err)
-		return
+	if useOffline && strings.TrimSpace(*hint) != "" {
+		fatihcolor.Yellow("规则生成不使用 -m 提示")
 	}
 
-	// 根据配置决定是否自动提交或询问用户
-	shouldCommit := cfg.AutoCommit
-	if !cfg.AutoCommit {
-		// 如果未启用自动提交，询问用户
-		shouldCommit = utils.ConfirmCommitMessage(commitMsg)
-	} else {
-		// 自动模式下也显示生成的提交消息
-		fatihcolor.Green("自动提交模式已启用")
-		fmt.Println("AI 生成的 Git 提交消息如下：")
-		fmt.Println(commitMsg)
+	// 用户选择带提示重新生成时，按新的提示再次生成并询问
+	var commitMsg string
+	var shouldCommit bool
+	generateHint := strings.TrimSpace(*hint)
+	for {
+		candidates, fellBack, err := generateCandidates(cfg, diff, *noCache, useOffline, generateHint)
+		if err != nil {
+			switch {
+			case errors.Is(err, context.Canceled):
+				fatihcolor.Yellow("已中断 AI 生成，未执行 Git 提交")
+				os.Exit(constants.ExitCodeCanceled)
+			case errors.Is(err, context.DeadlineExceeded):
+				fatihcolor.Red("AI 请求超时，未执行 Git 提交，可通过 request_timeout 调整超时时间")
+				os.Exit(constants.ExitCodeTimeout)
+			case errors.Is(err, usage.ErrBudgetExceeded):
+				fatihcolor.Red("%v", err)
+				fatihcolor.Yellow("已拒绝调用 AI，可调整 budget 配置或设置 fallback_model 改用本地模型")
+				os.Exit(1)
+			}
+			fatihcolor.Red("生成提交消息失败: %v", err)
+			return
+		}
+
+		candidates = applyTickets(cfg, candidates)
+
+		// 根据配置决定是否自动提交或询问用户，自动提交时使用第一条候选
+		// AI 失败后退回规则生成的消息总是需要用户确认
+		commitMsg = candidates[0]
+		autoCommit := cfg.AutoCommit && !fellBack
+		shouldCommit = autoCommit
+		if cfg.AutoCommit && fellBack {
+			fatihcolor.Yellow("提交消息由规则生成，需要确认后再提交")
+		}
+		if autoCommit {
+			// 自动模式下也显示生成的提交消息
+			fatihcolor.Green("自动提交模式已启用")
+			fmt.Println("生成的 Git 提交消息如下：")
+			fmt.Println(commitMsg)
+			break
+		}
+
+		// 如果未启用自动提交，询问用户；有多条候选时让用户从列表中选择
+		var nextHint string
+		if len(candidates) > 1 {
+			commitMsg, shouldCommit, nextHint = utils.SelectCommitMessage(candidates, !useOffline)
+		} else {
+			shouldCommit, nextHint = utils.ConfirmCommitMessage(commitMsg, !useOffline)
+		}
+		if nextHint == "" {
+			break
+		}
+		generateHint = nextHint
+		fmt.Println()
 	}
 
 	if shouldCommit {
@@ -174,6 +254,135 @@ func main() {
 	}
 }
 
+// warnInsecureTLS 在关闭证书校验时给出醒目的警告
+func warnInsecureTLS(cfg *config.Config) {
+	if cfg.InsecureSkipVerify {
+		fatihcolor.New(fatihcolor.FgHiRed, fatihcolor.Bold).Println(
+			"警告：已启用 insecure_skip_verify，访问 AI 服务时不校验 TLS 证书，请求内容（包括 API 密钥和代码 diff）可能被中间人窃取！")
+	}
+}
+
+// promptForField 获取配置项的值，模型名称优先从服务商的模型列表中选择
+func promptForField(field config.ConfigField) (string, error) {
+	if field.Name == "openai_model" {
+		// 重新加载以获取刚刚填写的 API 密钥和地址
+		if latest, err := config.LoadConfig(); err == nil {
+			model, err := selectModel(latest)
+			if err == nil {
+				return model, nil
+			}
+			fatihcolor.Yellow("%v，请手动输入模型名称", err)
+		}
+	}
+	return utils.PromptForValue(field)
+}
+
+// offlineFallback 判断 AI 生成失败时是否退回规则生成，未配置时默认启用
+func offlineFallback(cfg *config.Config) bool {
+	return cfg.OfflineFallback == nil || *cfg.OfflineFallback
+}
+
+// generateOfflineMessages 根据暂存区的文件变更按规则生成提交消息
+func generateOfflineMessages(cfg *config.Config) ([]string, error) {
+	files, err := utils.GetStagedFiles()
+	if err != nil {
+		return nil, err
+	}
+	style := openai_api.CommitStyle(cfg, files)
+	message := heuristic.Generate(files, cfg.CommitLocale, style)
+	if message == "" {
+		return nil, fmt.Errorf("暂存区没有文件变更")
+	}
+	if cfg.Body == constants.BodyAlways {
+		message += "\n\n" + heuristic.Body(files, cfg.CommitLocale)
+	}
+	// 检测到破坏性变更时加上标记和脚注
+	message = style.ApplyBreaking(message)
+	return []string{message}, nil
+}
+
+// applyTickets 从当前分支名中提取工单编号并写入每条候选提交消息，出错时给出提示并保留原消息
+func applyTickets(cfg *config.Config, candidates []string) []string {
+	if len(cfg.Tickets.Patterns) == 0 {
+		return candidates
+	}
+	branch, err := utils.GetCurrentBranch()
+	if err != nil {
+		return candidates
+	}
+	ids, err := ticket.Extract(branch, cfg.Tickets.Patterns)
+	if err != nil {
+		fatihcolor.Yellow("%v", err)
+		return candidates
+	}
+	if len(ids) == 0 {
+		return candidates
+	}
+
+	data := ticket.Data{ID: ids[0], IDs: ids, Branch: branch}
+	style := commitstyle.Get(cfg.CommitType)
+	result := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		message, err := ticket.Apply(candidate, data, cfg.Tickets.Position, cfg.Tickets.Template, style)
+		if err != nil {
+			fatihcolor.Yellow("%v", err)
+			return candidates
+		}
+		// 标题前加上工单编号后可能超出长度限制，需重新截断
+		if limited, truncated := openai_api.LimitSubject(cfg, message); truncated {
+			fatihcolor.Yellow("（加上工单编号后提交消息标题超出长度限制，已截断）")
+			message = limited
+		}
+		result = append(result, message)
+	}
+	return result
+}
+
+// generateCandidates 生成候选提交消息：离线模式按规则生成，否则调用 AI，AI 失败且允许时退回规则生成
+// 第二个返回值表示是否退回了规则生成
+func generateCandidates(cfg *config.Config, diff string, noCache, useOffline bool, hint string) ([]string, bool, error) {
+	if useOffline {
+		fatihcolor.Yellow("正在根据文件变更生成提交消息...")
+		candidates, err := generateOfflineMessages(cfg)
+		return candidates, false, err
+	}
+	// 使用 OpenAI API 生成提交消息
+	if hint != "" {
+		fatihcolor.Yellow("正在按提示使用 AI 生成提交消息...")
+	} else {
+		fatihcolor.Yellow("正在使用 AI 生成提交消息...")
+	}
+	candidates, err := generateCommitMessages(cfg, diff, noCache, hint)
+	if err != nil && canFallback(err) && offlineFallback(cfg) {
+		// AI 不可用时退回规则生成，作为最后的兜底
+		fatihcolor.Yellow("AI 生成提交消息失败: %v", err)
+		fatihcolor.Yellow("改用规则根据文件变更生成提交消息...")
+		candidates, err = generateOfflineMessages(cfg)
+		return candidates, true, err
+	}
+	return candidates, false, err
+}
+
+// canFallback 判断 AI 生成失败后能否退回规则生成
+// 中断、超时和超出预算需要按各自的退出码退出，不退回规则生成
+func canFallback(err error) bool {
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, usage.ErrBudgetExceeded)
+}
+
+// generateCommitMessages 在可被 Ctrl-C 中断的上下文中调用 AI 生成候选提交消息，生成内容实时输出到终端
+// 信号监听只在请求期间生效，之后的交互确认恢复默认的中断行为
+func generateCommitMessages(cfg *config.Config, diff string, noCache bool, hint string) ([]string, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return openai_api.GenerateCommitMessages(ctx, cfg, diff, openai_api.GenerateOptions{
+		Out:     os.Stdout,
+		NoCache: noCache,
+		Hint:    hint,
+	})
+}
+
 // printColoredDiff 打印彩色的 diff 输出
 func printColoredDiff(diff string) {
 	lines := strings.Split(diff, "\n")
@@ -0,0 +1,257 @@
+// Package breaking 根据暂存区的改动检测可能破坏兼容性的变更：删除或修改 Go 导出符号、删除命令行参数和配置项
+package breaking
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/feiandxs/agcommits/utils"
+)
+
+var (
+	// flagPattern 匹配标准库 flag 和 pflag 风格的参数定义，分组为参数名
+	flagPattern = regexp.MustCompile(`\b(?:flag|flags|fs|\w*[Ff]lags\(\))\.(?:String|Bool|Int|Int64|Uint|Uint64|Float64|Duration|StringSlice|StringArray|Count|Var|Func)(?:Var)?P?\(\s*(?:&?[\w.\[\]]+,\s*)?"([\w-]+)"`)
+
+	// configKeyPattern 匹配配置结构体的字段标签，分组为配置项名称
+	configKeyPattern = regexp.MustCompile("(?:yaml|toml|mapstructure):\"([\\w.-]+)")
+)
+
+// symbol 导出符号，name 为 "函数"、"类型.方法" 或 "类型.字段"
+type symbol struct {
+	dir  string
+	name string
+}
+
+// snapshot 一组文件中对外可见的接口
+type snapshot struct {
+	symbols    map[symbol]string // 导出符号到其签名的映射
+	packages   map[string]string // 目录到包名的映射
+	flags      map[string]bool
+	configKeys map[string]bool
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		symbols:    map[symbol]string{},
+		packages:   map[string]string{},
+		flags:      map[string]bool{},
+		configKeys: map[string]bool{},
+	}
+}
+
+// Detect 比较暂存区 Go 源文件在 HEAD 和暂存区中的内容，返回可能破坏兼容性的变更说明
+// 只读取 Go 源文件；没有 HEAD（首次提交）或读取失败的文件会被跳过
+func Detect(files []utils.StagedFile) []string {
+	before, after := newSnapshot(), newSnapshot()
+	for _, file := range files {
+		oldPath := file.Path
+		if file.OldPath != "" {
+			oldPath = file.OldPath
+		}
+		if file.Status != "A" && file.Status != "C" && isGoSource(oldPath) {
+			if content, err := utils.GetHeadFileContent(oldPath); err == nil {
+				before.add(oldPath, content)
+			}
+		}
+		if file.Status != "D" && isGoSource(file.Path) {
+			if content, err := utils.GetStagedFileContent(file.Path); err == nil {
+				after.add(file.Path, content)
+			}
+		}
+	}
+	return compare(before, after)
+}
+
+// isGoSource 判断文件是否为需要检测的 Go 源文件，测试文件不对外提供接口
+func isGoSource(file string) bool {
+	return strings.HasSuffix(file, ".go") && !strings.HasSuffix(file, "_test.go")
+}
+
+// add 收集单个 Go 源文件中的导出符号、命令行参数和配置项
+func (s *snapshot) add(file string, content []byte) {
+	source := string(content)
+	for _, match := range flagPattern.FindAllStringSubmatch(source, -1) {
+		s.flags[match[1]] = true
+	}
+	for _, match := range configKeyPattern.FindAllStringSubmatch(source, -1) {
+		s.configKeys[match[1]] = true
+	}
+
+	// internal 包和 main 包不对外提供 Go 接口
+	if strings.Contains("/"+file, "/internal/") || strings.Contains("/"+file, "/testdata/") {
+		return
+	}
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseFile(fset, file, content, parser.SkipObjectResolution)
+	if err != nil || parsed.Name.Name == "main" {
+		return
+	}
+	dir := path.Dir(file)
+	s.packages[dir] = parsed.Name.Name
+
+	for _, decl := range parsed.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name, kind := decl.Name.Name, "func"
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				receiver := receiverName(decl.Recv.List[0].Type)
+				if !ast.IsExported(receiver) {
+					continue
+				}
+				name, kind = receiver+"."+name, "method"
+			}
+			if decl.Name.IsExported() {
+				s.symbols[symbol{dir, name}] = kind + " " + signature(fset, decl.Type)
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+					s.symbols[symbol{dir, spec.Name.Name}] = "type"
+					if structType, ok := spec.Type.(*ast.StructType); ok {
+						for _, field := range structType.Fields.List {
+							for _, fieldName := range field.Names {
+								if fieldName.IsExported() {
+									s.symbols[symbol{dir, spec.Name.Name + "." + fieldName.Name}] = "field " + nodeString(fset, field.Type)
+								}
+							}
+						}
+					}
+				case *ast.ValueSpec:
+					for _, valueName := range spec.Names {
+						if valueName.IsExported() {
+							s.symbols[symbol{dir, valueName.Name}] = decl.Tok.String()
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// compare 对比改动前后的接口，返回被删除或修改的部分
+func compare(before, after *snapshot) []string {
+	var changes []string
+
+	keys := make([]symbol, 0, len(before.symbols))
+	for key := range before.symbols {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].dir != keys[j].dir {
+			return keys[i].dir < keys[j].dir
+		}
+		return keys[i].name < keys[j].name
+	})
+	for _, key := range keys {
+		qualified := before.packages[key.dir] + "." + key.name
+		oldSignature := before.symbols[key]
+		kind := strings.SplitN(oldSignature, " ", 2)[0]
+		newSignature, ok := after.symbols[key]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("removed exported %s %s", kindName(kind), qualified))
+		case newSignature != oldSignature && kind != "type":
+			changes = append(changes, fmt.Sprintf("changed %s of %s", changedName(kind), qualified))
+		}
+	}
+
+	for _, flag := range removed(before.flags, after.flags) {
+		changes = append(changes, fmt.Sprintf("removed command line flag --%s", flag))
+	}
+	for _, key := range removed(before.configKeys, after.configKeys) {
+		changes = append(changes, fmt.Sprintf("removed config key %s", key))
+	}
+	return changes
+}
+
+// removed 返回只存在于 before 中的名称
+func removed(before, after map[string]bool) []string {
+	var names []string
+	for name := range before {
+		if !after[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// kindName 返回符号种类的英文名称
+func kindName(kind string) string {
+	switch kind {
+	case "func":
+		return "function"
+	case "var":
+		return "variable"
+	case "const":
+		return "constant"
+	}
+	return kind
+}
+
+// changedName 返回符号被修改的部分
+func changedName(kind string) string {
+	if kind == "field" {
+		return "type of field"
+	}
+	return "signature"
+}
+
+// receiverName 返回方法接收者的类型名
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// signature 返回函数签名，只包含参数和返回值的类型，忽略参数名
+func signature(fset *token.FileSet, funcType *ast.FuncType) string {
+	return "(" + fieldTypes(fset, funcType.Params) + ") (" + fieldTypes(fset, funcType.Results) + ")"
+}
+
+// fieldTypes 按顺序列出字段的类型，多个同类型参数分别列出
+func fieldTypes(fset *token.FileSet, fields *ast.FieldList) string {
+	if fields == nil {
+		return ""
+	}
+	var types []string
+	for _, field := range fields.List {
+		typeString := nodeString(fset, field.Type)
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			types = append(types, typeString)
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+// nodeString 将语法树节点打印为源码文本
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	var builder strings.Builder
+	if err := printer.Fprint(&builder, fset, node); err != nil {
+		return ""
+	}
+	return builder.String()
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheDirName 缓存目录名称，位于系统用户缓存目录下
+const cacheDirName = "agcommits"
+
+// entry 单条缓存记录在磁盘上的结构
+type entry struct {
+	CreatedAt time.Time `json:"created_at"`
+	Messages  []string  `json:"messages"`
+}
+
+// Cache 基于文件的 AI 响应缓存，每条记录保存为一个以键命名的 JSON 文件
+type Cache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+}
+
+// Dir 返回缓存目录的完整路径
+func Dir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, cacheDirName), nil
+}
+
+// New 创建缓存实例，ttl 为记录有效期，maxEntries 为最多保留的记录数
+func New(ttl time.Duration, maxEntries int) (*Cache, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, ttl: ttl, maxEntries: maxEntries}, nil
+}
+
+// Key 根据请求的各组成部分计算缓存键
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get 读取未过期的缓存记录
+func (c *Cache) Get(key string) ([]string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || len(e.Messages) == 0 {
+		return nil, false
+	}
+	if time.Since(e.CreatedAt) > c.ttl {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return e.Messages, true
+}
+
+// Put 写入缓存记录（一次生成的全部候选消息），并清理过期及超出数量上限的旧记录
+func (c *Cache) Put(key string, messages []string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{CreatedAt: time.Now(), Messages: messages})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path(key), data, 0644); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+// prune 删除过期记录，并按修改时间从旧到新删除超出上限的记录
+func (c *Cache) prune() error {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	type cachedFile struct {
+		path    string
+		modTime time.Time
+	}
+	var kept []cachedFile
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > c.ttl {
+			os.Remove(file)
+			continue
+		}
+		kept = append(kept, cachedFile{path: file, modTime: info.ModTime()})
+	}
+
+	if len(kept) <= c.maxEntries {
+		return nil
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].modTime.Before(kept[j].modTime)
+	})
+	for _, file := range kept[:len(kept)-c.maxEntries] {
+		os.Remove(file.path)
+	}
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Clear 删除全部缓存记录
+func Clear() error {
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package commitstyle
+
+import (
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// InferScope 根据变更文件路径推断 scope
+// 优先使用 scopeMap 中匹配的路径规则（规则越长越优先），未匹配时 Go 文件使用包名，其他文件使用顶层目录
+// 文件对应多个 scope 时取覆盖文件最多的一个；paths 为相对仓库根目录的路径，repoRoot 用于读取 Go 包名
+func InferScope(paths []string, scopeMap map[string]string, repoRoot string) string {
+	patterns := make([]string, 0, len(scopeMap))
+	for pattern := range scopeMap {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	counts := map[string]int{}
+	for _, file := range paths {
+		scope := ""
+		for _, pattern := range patterns {
+			if matchGlob(pattern, file) {
+				scope = scopeMap[pattern]
+				break
+			}
+		}
+		if scope == "" {
+			scope = defaultScope(file, repoRoot)
+		}
+		if scope != "" {
+			counts[scope]++
+		}
+	}
+
+	best := ""
+	for scope, count := range counts {
+		if count > counts[best] || (count == counts[best] && scope < best) {
+			best = scope
+		}
+	}
+	return best
+}
+
+// defaultScope 未配置路径规则时的 scope：Go 文件使用包名，其他文件使用顶层目录，根目录下的文件没有 scope
+func defaultScope(file, repoRoot string) string {
+	dir := path.Dir(file)
+	if dir == "." || strings.HasPrefix(file, ".") {
+		return ""
+	}
+	if strings.HasSuffix(file, ".go") {
+		return goPackageName(file, repoRoot)
+	}
+	return strings.SplitN(file, "/", 2)[0]
+}
+
+// goPackageName 读取 Go 文件的包名，文件已删除或为 main 包时使用目录名
+func goPackageName(file, repoRoot string) string {
+	dirName := path.Base(path.Dir(file))
+	parsed, err := parser.ParseFile(token.NewFileSet(), filepath.Join(repoRoot, filepath.FromSlash(file)), nil, parser.PackageClauseOnly)
+	if err != nil {
+		return dirName
+	}
+	name := strings.TrimSuffix(parsed.Name.Name, "_test")
+	if name == "main" {
+		return dirName
+	}
+	return name
+}
+
+// matchGlob 判断路径是否匹配规则
+// 支持 * 和 ? 通配单级路径，** 通配任意多级；以 / 结尾的规则匹配目录下的全部文件；不含 / 的规则同时匹配文件名
+func matchGlob(pattern, file string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(pattern, "/") && globPattern(pattern).MatchString(path.Base(file)) {
+		return true
+	}
+	return globPattern(pattern).MatchString(file)
+}
+
+// globPattern 将路径规则转换为正则表达式
+func globPattern(pattern string) *regexp.Regexp {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				// "**/" 可以匹配零级目录
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					builder.WriteString("(.*/)?")
+				} else {
+					builder.WriteString(".*")
+				}
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.MustCompile(builder.String())
+}
@@ -0,0 +1,543 @@
+// Package commitstyle 定义各种提交消息格式的格式说明、类型指引、渲染和校验规则
+package commitstyle
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/feiandxs/agcommits/constants"
+)
+
+// Commit 结构化的提交信息
+type Commit struct {
+	Type     string
+	Scope    string
+	Subject  string
+	Body     string
+	Breaking bool
+	Footers  []string
+}
+
+// Type 提交类型及其说明
+type Type struct {
+	Name        string
+	Description string
+}
+
+// conventionalTypes 约定式提交的类型
+var conventionalTypes = []Type{
+	{"docs", "Documentation only changes"},
+	{"style", "Changes that do not affect the meaning of the code (white-space, formatting, etc)"},
+	{"refactor", "A code change that neither fixes a bug nor adds a feature"},
+	{"perf", "A code change that improves performance"},
+	{"test", "Adding missing tests or correcting existing tests"},
+	{"build", "Changes that affect the build system or external dependencies"},
+	{"ci", "Changes to our CI configuration files and scripts"},
+	{"chore", "Other changes that don't modify src or test files"},
+	{"revert", "Reverts a previous commit"},
+	{"feat", "A new feature"},
+	{"fix", "A bug fix"},
+}
+
+// angularTypes Angular 提交规范的类型
+var angularTypes = []Type{
+	{"build", "Changes that affect the build system or external dependencies"},
+	{"ci", "Changes to our CI configuration files and scripts"},
+	{"docs", "Documentation only changes"},
+	{"feat", "A new feature"},
+	{"fix", "A bug fix"},
+	{"perf", "A code change that improves performance"},
+	{"refactor", "A code change that neither fixes a bug nor adds a feature"},
+	{"test", "Adding missing tests or correcting existing tests"},
+}
+
+// gitmojis 提交类型对应的 gitmoji 表情
+var gitmojis = []struct {
+	Type  string
+	Emoji string
+	Usage string
+}{
+	{"feat", "✨", "Introduce new features"},
+	{"fix", "🐛", "Fix a bug"},
+	{"docs", "📝", "Add or update documentation"},
+	{"style", "🎨", "Improve structure / format of the code"},
+	{"refactor", "♻️", "Refactor code"},
+	{"perf", "⚡️", "Improve performance"},
+	{"test", "✅", "Add, update, or pass tests"},
+	{"build", "📦️", "Add or update compiled files, packages or dependencies"},
+	{"ci", "👷", "Add or update CI build system"},
+	{"chore", "🔧", "Add or update configuration files"},
+	{"revert", "⏪️", "Revert changes"},
+	{"remove", "🔥", "Remove code or files"},
+	{"breaking", "💥", "Introduce breaking changes"},
+}
+
+var (
+	conventionalHeaderPattern = regexp.MustCompile(`^([a-zA-Z0-9_-]+)(\(([^()]+)\))?(!)?: \S`)
+	gitmojiHeaderPattern      = regexp.MustCompile(`^(:[a-z0-9_+-]+:|\p{So})`)
+	kernelHeaderPattern       = regexp.MustCompile(`^[a-zA-Z0-9_./-]+(, ?[a-zA-Z0-9_./-]+)*: \S`)
+
+	// footerPattern 匹配提交消息的脚注行，如 "Refs: #123"、"BREAKING CHANGE: ..."
+	footerPattern = regexp.MustCompile(`^([A-Z][A-Za-z-]*|BREAKING CHANGE)(: | #)`)
+)
+
+// BreakingFooter 破坏性变更的脚注标记
+const BreakingFooter = "BREAKING CHANGE"
+
+// Style 一种提交消息格式
+type Style struct {
+	Name   string
+	Types  []Type
+	Scopes []string
+	Scope  string // 根据变更路径推断出的 scope，要求模型使用
+
+	Breaking []string // 检测到的可能破坏兼容性的变更
+
+	enforceBreaking bool // 是否由 ApplyBreaking 补上模型遗漏的破坏性变更标记
+}
+
+// Get 返回指定名称的提交格式，为空时使用约定式提交，未知名称按自由格式处理
+func Get(name string) Style {
+	switch name {
+	case "", constants.ConventionalCommitType:
+		return Style{Name: constants.ConventionalCommitType, Types: conventionalTypes}
+	case constants.AngularCommitType:
+		return Style{Name: name, Types: angularTypes}
+	case constants.GitmojiCommitType, constants.KernelCommitType:
+		return Style{Name: name}
+	}
+	return Style{Name: constants.FreeFormCommitType}
+}
+
+// Customize 使用项目定义的提交类型替换内置类型，并限定可用的 scope，参数为空时保持不变
+func (s Style) Customize(types []Type, scopes []string) Style {
+	if len(types) > 0 {
+		s.Types = types
+	}
+	s.Scopes = scopes
+	return s
+}
+
+// WithScope 要求模型使用根据变更路径推断出的 scope；限定了 scope 列表而推断结果不在其中时忽略
+func (s Style) WithScope(scope string) Style {
+	if scope != "" && s.hasScope(scope) {
+		s.Scope = scope
+	}
+	return s
+}
+
+// WithBreaking 在提示词中告知模型检测到的破坏性变更；enforce 为 true 时要求标记，并由 ApplyBreaking 补上遗漏的标记
+func (s Style) WithBreaking(changes []string, enforce bool) Style {
+	s.Breaking = changes
+	s.enforceBreaking = enforce
+	return s
+}
+
+// EnforcesBreaking 判断是否检测到破坏性变更且要求提交消息必须标记
+func (s Style) EnforcesBreaking() bool {
+	return len(s.Breaking) > 0 && s.enforceBreaking
+}
+
+// Format 返回写入提示词的格式说明
+func (s Style) Format() string {
+	switch s.Name {
+	case constants.ConventionalCommitType:
+		return "<type>(<optional scope>): <commit message>"
+	case constants.AngularCommitType:
+		return "<type>(<scope>): <commit message>"
+	case constants.GitmojiCommitType:
+		return "<gitmoji> <commit message>"
+	case constants.KernelCommitType:
+		return "<subsystem>: <summary>"
+	}
+	return ""
+}
+
+// Guidance 返回写入提示词的类型选择说明
+func (s Style) Guidance() string {
+	var builder strings.Builder
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		builder.WriteString("\nChoose a type that best describes the git diff:\n")
+		for _, t := range s.Types {
+			if t.Description == "" {
+				fmt.Fprintf(&builder, "- %s\n", t.Name)
+			} else {
+				fmt.Fprintf(&builder, "- %s: %s\n", t.Name, t.Description)
+			}
+		}
+		builder.WriteString(s.scopeGuidance())
+		first, second := s.exampleTypes()
+		if s.Name == constants.AngularCommitType {
+			scope := s.Scope
+			if scope == "" && len(s.Scopes) > 0 {
+				scope = s.Scopes[0]
+			} else if scope == "" {
+				scope = "api"
+			}
+			fmt.Fprintf(&builder, "\nYou should generate a commit message like:\n%s(%s): xxxxx\nor\n%s(%s): xxxxx\n", first, scope, second, scope)
+		} else if s.Scope != "" {
+			fmt.Fprintf(&builder, "\nYou should generate a commit message like:\n%s(%s): xxxxx\nor\n%s(%s): xxxxx\n", first, s.Scope, second, s.Scope)
+		} else {
+			fmt.Fprintf(&builder, "\nYou should generate a commit message like:\n%s: xxxxx\nor\n%s: xxxxx\n", first, second)
+		}
+	case constants.GitmojiCommitType:
+		builder.WriteString("\nStart the message with the gitmoji that best describes the git diff:\n")
+		for _, g := range gitmojis {
+			fmt.Fprintf(&builder, "- %s %s\n", g.Emoji, g.Usage)
+		}
+		builder.WriteString("\nYou should generate a commit message like:\n✨ xxxxx\nor\n🐛 xxxxx\n")
+	case constants.KernelCommitType:
+		builder.WriteString("\nPrefix the summary with the subsystem or component affected, usually derived from the changed file paths " +
+			"(e.g. net/ipv4 or drivers/usb), followed by a colon and an imperative summary.\n" +
+			"Do not use type prefixes such as feat or fix.\n")
+		builder.WriteString(s.scopeGuidance())
+		builder.WriteString("\nYou should generate a commit message like:\nparser: xxxxx\n")
+	}
+	builder.WriteString(s.breakingGuidance())
+	return builder.String()
+}
+
+// breakingGuidance 返回破坏性变更的标记要求，未检测到时为空
+func (s Style) breakingGuidance() string {
+	if len(s.Breaking) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("\nThe diff contains likely breaking changes:\n")
+	for _, change := range s.Breaking {
+		fmt.Fprintf(&builder, "- %s\n", change)
+	}
+	// prompt 模式下由模型判断这些变更是否影响项目的使用者
+	mark := "Mark the commit as breaking: "
+	if !s.enforceBreaking {
+		mark = "If they break users of the project (not just internal code), mark the commit as breaking: "
+	}
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		builder.WriteString(mark + "add ! before the colon (e.g. feat!: xxxxx or feat(api)!: xxxxx) ")
+	case constants.GitmojiCommitType:
+		builder.WriteString(mark + "start the message with 💥 ")
+	default:
+		builder.WriteString(mark)
+	}
+	builder.WriteString("and end the message with a footer \"" + BreakingFooter + ": <what breaks and how to migrate>\" after a blank line.\n")
+	return builder.String()
+}
+
+// scopeGuidance 返回 scope 的说明，未限定 scope 且没有推断出 scope 时为空
+func (s Style) scopeGuidance() string {
+	var builder strings.Builder
+	if len(s.Scopes) > 0 {
+		list := strings.Join(s.Scopes, ", ")
+		switch s.Name {
+		case constants.AngularCommitType:
+			builder.WriteString("The scope is required and must be one of: " + list + "\n")
+		case constants.KernelCommitType:
+			builder.WriteString("The subsystem must be one of: " + list + "\n")
+		default:
+			builder.WriteString("The scope is optional, but if present it must be one of: " + list + "\n")
+		}
+	}
+	if s.Scope != "" {
+		if s.Name == constants.KernelCommitType {
+			fmt.Fprintf(&builder, "Use \"%s\" as the subsystem.\n", s.Scope)
+		} else {
+			fmt.Fprintf(&builder, "Use \"%s\" as the scope.\n", s.Scope)
+		}
+	}
+	return builder.String()
+}
+
+// exampleTypes 返回提示词示例中使用的两个类型，优先使用 feat 和 fix
+func (s Style) exampleTypes() (string, string) {
+	if s.hasType("feat") && s.hasType("fix") {
+		return "feat", "fix"
+	}
+	if len(s.Types) == 1 {
+		return s.Types[0].Name, s.Types[0].Name
+	}
+	return s.Types[0].Name, s.Types[1].Name
+}
+
+// Correction 返回提交消息不符合格式时要求模型重新生成的说明
+func (s Style) Correction() string {
+	var builder strings.Builder
+	builder.WriteString("The commit message above does not follow the required format. " +
+		"Generate it again for the same diff, in the same output format as required before.\n")
+	if format := s.Format(); format != "" {
+		fmt.Fprintf(&builder, "Required format: %s\n", format)
+	}
+	if len(s.Types) > 0 && (s.Name == constants.ConventionalCommitType || s.Name == constants.AngularCommitType) {
+		var names []string
+		for _, t := range s.Types {
+			names = append(names, t.Name)
+		}
+		fmt.Fprintf(&builder, "The type must be one of: %s\n", strings.Join(names, ", "))
+	}
+	builder.WriteString(s.scopeGuidance())
+	return builder.String()
+}
+
+// Render 按提交格式将结构化提交信息渲染为提交消息，模型未给出 scope 时使用推断的 scope
+func (s Style) Render(commit Commit) string {
+	if strings.TrimSpace(commit.Scope) == "" {
+		commit.Scope = s.Scope
+	}
+	parts := []string{s.header(commit)}
+	if body := strings.TrimSpace(commit.Body); body != "" {
+		parts = append(parts, body)
+	}
+
+	var footers []string
+	for _, footer := range commit.Footers {
+		if footer = strings.TrimSpace(footer); footer != "" {
+			footers = append(footers, footer)
+		}
+	}
+	if len(footers) > 0 {
+		parts = append(parts, strings.Join(footers, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// header 渲染提交消息的标题行
+func (s Style) header(commit Commit) string {
+	commitType := strings.TrimSpace(commit.Type)
+	scope := strings.TrimSpace(commit.Scope)
+	subject := strings.TrimSpace(commit.Subject)
+
+	var prefix string
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		prefix = commitType
+		if scope != "" {
+			prefix += "(" + scope + ")"
+		}
+		if commit.Breaking && prefix != "" {
+			prefix += "!"
+		}
+		if prefix != "" {
+			prefix += ":"
+		}
+	case constants.GitmojiCommitType:
+		prefix = emojiFor(commitType)
+		if commit.Breaking {
+			prefix = emojiFor("breaking")
+		}
+	case constants.KernelCommitType:
+		prefix = scope
+		if prefix == "" {
+			prefix = commitType
+		}
+		if prefix != "" {
+			prefix += ":"
+		}
+	}
+	if prefix == "" {
+		return subject
+	}
+	return prefix + " " + subject
+}
+
+// emojiFor 返回提交类型对应的 gitmoji，类型本身已是表情时原样返回
+func emojiFor(commitType string) string {
+	for _, g := range gitmojis {
+		if g.Type == commitType {
+			return g.Emoji
+		}
+	}
+	if gitmojiHeaderPattern.MatchString(commitType) {
+		return commitType
+	}
+	return "🔧"
+}
+
+// ApplyScope 提交消息标题缺少 scope 时补上推断的 scope
+func (s Style) ApplyScope(message string) string {
+	if s.Scope == "" {
+		return message
+	}
+	lines := strings.SplitN(message, "\n", 2)
+	header := lines[0]
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		match := conventionalHeaderPattern.FindStringSubmatchIndex(header)
+		// match[6] 为 scope 分组的起始位置，-1 表示没有 scope
+		if match == nil || match[6] >= 0 {
+			return message
+		}
+		header = header[:match[3]] + "(" + s.Scope + ")" + header[match[3]:]
+	case constants.KernelCommitType:
+		if kernelHeaderPattern.MatchString(header) {
+			return message
+		}
+		header = s.Scope + ": " + header
+	default:
+		return message
+	}
+	lines[0] = header
+	return strings.Join(lines, "\n")
+}
+
+// ApplyBreaking 要求标记破坏性变更而提交消息未标记时，补上标题中的标记（! 或 💥）和 BREAKING CHANGE 脚注
+func (s Style) ApplyBreaking(message string) string {
+	if !s.EnforcesBreaking() {
+		return message
+	}
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		// match[8] 为 ! 分组的起始位置，-1 表示未标记；! 插在类型或 scope 之后
+		if match := conventionalHeaderPattern.FindStringSubmatchIndex(lines[0]); match != nil && match[8] < 0 {
+			end := match[3]
+			if match[5] >= 0 {
+				end = match[5]
+			}
+			lines[0] = lines[0][:end] + "!" + lines[0][end:]
+		}
+	case constants.GitmojiCommitType:
+		lines[0] = breakingGitmojiHeader(lines[0])
+	}
+
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, BreakingFooter+":") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			return strings.Join(lines, "\n")
+		}
+	}
+	return AppendFooter(strings.Join(lines, "\n"), BreakingFooter+": "+strings.Join(s.Breaking, "; "))
+}
+
+// breakingGitmojiHeader 将标题开头的 gitmoji 替换为 💥，没有表情时在开头加上
+func breakingGitmojiHeader(header string) string {
+	breaking := emojiFor("breaking")
+	rest := header
+	if match := gitmojiHeaderPattern.FindStringIndex(header); match != nil {
+		emoji := header[:match[1]]
+		if emoji == breaking || emoji == ":boom:" {
+			return header
+		}
+		rest = header[match[1]:]
+	}
+	return breaking + " " + strings.TrimLeft(rest, "\uFE0F ")
+}
+
+// AppendFooter 在提交消息末尾添加脚注：已有脚注段落时追加到其中，否则另起一段
+func AppendFooter(message, footer string) string {
+	message = strings.TrimSpace(message)
+	lines := strings.Split(message, "\n")
+	if len(lines) > 1 && IsFooterLine(lines[len(lines)-1]) {
+		return message + "\n" + footer
+	}
+	return message + "\n\n" + footer
+}
+
+// PrefixSubject 在标题的描述部分前加上前缀，类型、scope 或子系统等格式前缀保持在最前面
+func (s Style) PrefixSubject(message, prefix string) string {
+	header, rest, hasRest := strings.Cut(message, "\n")
+	start := 0
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType, constants.KernelCommitType:
+		if conventionalHeaderPattern.MatchString(header) || kernelHeaderPattern.MatchString(header) {
+			start = strings.Index(header, ": ") + 2
+		}
+	case constants.GitmojiCommitType:
+		if match := gitmojiHeaderPattern.FindStringIndex(header); match != nil {
+			// 跳过表情后的变体选择符和空白
+			start = len(header) - len(strings.TrimLeft(header[match[1]:], "\uFE0F "))
+		}
+	}
+	header = header[:start] + prefix + header[start:]
+	if hasRest {
+		return header + "\n" + rest
+	}
+	return header
+}
+
+// IsFooterLine 判断是否为脚注行，如 "Refs: #123"、"BREAKING CHANGE: ..."
+func IsFooterLine(line string) bool {
+	return footerPattern.MatchString(strings.TrimSpace(line))
+}
+
+// Validate 检查提交消息的标题行是否符合提交格式
+func (s Style) Validate(message string) error {
+	header := strings.TrimSpace(strings.SplitN(strings.TrimSpace(message), "\n", 2)[0])
+	if header == "" {
+		return fmt.Errorf("提交消息为空")
+	}
+
+	switch s.Name {
+	case constants.ConventionalCommitType, constants.AngularCommitType:
+		match := conventionalHeaderPattern.FindStringSubmatch(header)
+		if match == nil {
+			return fmt.Errorf("标题应为 %s 格式", s.Format())
+		}
+		if !s.hasType(match[1]) {
+			return fmt.Errorf("不支持的提交类型 %s", match[1])
+		}
+		if s.Name == constants.AngularCommitType && match[3] == "" {
+			return fmt.Errorf("Angular 格式要求标题带有 scope")
+		}
+		if match[3] != "" && !s.hasScope(match[3]) {
+			return fmt.Errorf("不支持的 scope %s", match[3])
+		}
+	case constants.GitmojiCommitType:
+		if !gitmojiHeaderPattern.MatchString(header) {
+			return fmt.Errorf("标题应以 gitmoji 表情开头")
+		}
+	case constants.KernelCommitType:
+		if !kernelHeaderPattern.MatchString(header) {
+			return fmt.Errorf("标题应为 %s 格式", s.Format())
+		}
+		for _, subsystem := range strings.Split(header[:strings.Index(header, ":")], ",") {
+			subsystem = strings.TrimSpace(subsystem)
+			// 提交类型（如 feat、fix）不能代替子系统，除非项目在 scopes 中明确将其列为子系统
+			if isCommitTypeName(subsystem) && !s.listsScope(subsystem) {
+				return fmt.Errorf("标题应以子系统开头，不应使用提交类型 %s", subsystem)
+			}
+			if !s.hasScope(subsystem) {
+				return fmt.Errorf("不支持的子系统 %s", subsystem)
+			}
+		}
+	}
+	return nil
+}
+
+// hasType 判断提交类型是否在当前格式允许的类型中
+func (s Style) hasType(name string) bool {
+	for _, t := range s.Types {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// listsScope 判断 scope 是否在项目明确列出的 scope 列表中
+func (s Style) listsScope(scope string) bool {
+	for _, allowed := range s.Scopes {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommitTypeName 判断名称是否为约定式或 Angular 提交规范中的提交类型
+func isCommitTypeName(name string) bool {
+	name = strings.ToLower(name)
+	for _, types := range [][]Type{conventionalTypes, angularTypes} {
+		for _, t := range types {
+			if t.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasScope 判断 scope 是否可用，未限定 scope 时总是可用
+func (s Style) hasScope(scope string) bool {
+	return len(s.Scopes) == 0 || s.listsScope(scope)
+}
@@ -0,0 +1,250 @@
+// Package heuristic 根据暂存区的文件变更列表按规则生成提交消息，无需网络和 API 密钥
+package heuristic
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+	"github.com/feiandxs/agcommits/utils"
+)
+
+// 文件类别，决定提交类型
+const (
+	categoryCode  = "code"
+	categoryDocs  = "docs"
+	categoryTest  = "test"
+	categoryCI    = "ci"
+	categoryBuild = "build"
+)
+
+// buildFiles 构建和依赖相关的文件名
+var buildFiles = map[string]bool{
+	"go.mod": true, "go.sum": true, "makefile": true, "dockerfile": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"cargo.toml": true, "cargo.lock": true, "requirements.txt": true, "pyproject.toml": true,
+	"pom.xml": true, "build.gradle": true, ".goreleaser.yaml": true, ".goreleaser.yml": true,
+}
+
+// docExtensions 文档文件的扩展名
+var docExtensions = map[string]bool{".md": true, ".rst": true, ".adoc": true, ".txt": true}
+
+// Generate 根据暂存区的文件变更按 style 指定的格式生成提交消息，locale 为 zh 时使用中文描述，其余语言使用英文
+func Generate(files []utils.StagedFile, locale string, style commitstyle.Style) string {
+	if len(files) == 0 {
+		return ""
+	}
+	category := commitCategory(files)
+	commit := commitstyle.Commit{
+		Type:    category,
+		Scope:   style.Scope,
+		Subject: subject(files, category, locale),
+	}
+	if category == categoryCode {
+		commit.Type = codeCommitType(files)
+		// 破坏兼容性的代码改动按新功能处理，渲染为 feat!
+		if style.EnforcesBreaking() {
+			commit.Type = "feat"
+		}
+	}
+	commit.Breaking = style.EnforcesBreaking()
+
+	// Angular 和内核风格要求标题带有 scope 或子系统
+	if commit.Scope == "" && (style.Name == constants.AngularCommitType || style.Name == constants.KernelCommitType) {
+		commit.Scope = fallbackScope(files[0].Path)
+	}
+	return style.Render(commit)
+}
+
+// fallbackScope 没有推断出 scope 时，以第一个文件的顶层目录或文件名作为 scope
+func fallbackScope(file string) string {
+	if parts := strings.SplitN(file, "/", 2); len(parts) == 2 && !strings.HasPrefix(parts[0], ".") {
+		return parts[0]
+	}
+	return displayName(file)
+}
+
+// classify 判断单个文件的类别
+func classify(file string) string {
+	lower := strings.ToLower(file)
+	base := path.Base(lower)
+	switch {
+	case strings.HasPrefix(lower, ".github/workflows/"), strings.HasPrefix(lower, ".circleci/"),
+		base == ".gitlab-ci.yml", base == ".travis.yml", base == "jenkinsfile":
+		return categoryCI
+	case buildFiles[base]:
+		return categoryBuild
+	case strings.HasSuffix(base, "_test.go"), strings.Contains(base, ".test."), strings.Contains(base, ".spec."),
+		strings.HasPrefix(base, "test_"), hasDir(lower, "test", "tests", "testdata", "__tests__"):
+		return categoryTest
+	case docExtensions[path.Ext(base)], strings.HasPrefix(base, "license"), hasDir(lower, "docs", "doc"):
+		return categoryDocs
+	}
+	return categoryCode
+}
+
+// hasDir 判断路径中是否包含指定名称的目录
+func hasDir(file string, names ...string) bool {
+	dirs := strings.Split(path.Dir(file), "/")
+	for _, dir := range dirs {
+		for _, name := range names {
+			if dir == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commitCategory 所有文件属于同一类别时返回该类别；测试与代码混合时按代码处理
+func commitCategory(files []utils.StagedFile) string {
+	categories := map[string]bool{}
+	for _, file := range files {
+		categories[classify(file.Path)] = true
+	}
+	if len(categories) == 1 {
+		for category := range categories {
+			return category
+		}
+	}
+	return categoryCode
+}
+
+// codeCommitType 根据代码文件的变更状态推断提交类型：全部为新增文件时为 feat，
+// 其余代码改动无法判断是修复还是新功能，按 refactor 处理（chore 只用于不涉及源码和测试的改动）
+func codeCommitType(files []utils.StagedFile) string {
+	if commonStatus(files) == "A" {
+		return "feat"
+	}
+	return "refactor"
+}
+
+// commonStatus 所有文件变更状态相同时返回该状态，否则返回空字符串
+// 内容未变的重命名视为 R，带修改的重命名视为 M
+func commonStatus(files []utils.StagedFile) string {
+	status := ""
+	for i, file := range files {
+		current := file.Status
+		if current == "R" && (file.Added > 0 || file.Deleted > 0) {
+			current = "M"
+		}
+		if current == "C" {
+			current = "A"
+		}
+		if i > 0 && current != status {
+			return ""
+		}
+		status = current
+	}
+	return status
+}
+
+// subject 生成提交消息的描述部分
+func subject(files []utils.StagedFile, category, locale string) string {
+	status := commonStatus(files)
+	target := describeTargets(files, locale)
+
+	if locale == "zh" {
+		if category == categoryTest {
+			if status == "A" {
+				return "补充" + target + "的测试用例"
+			}
+			return "更新" + target + "的测试用例"
+		}
+		if status == "R" && len(files) == 1 {
+			return fmt.Sprintf("将 %s 重命名为 %s", files[0].OldPath, files[0].Path)
+		}
+		verbs := map[string]string{"A": "新增", "D": "删除", "R": "重命名"}
+		verb, ok := verbs[status]
+		if !ok {
+			verb = "更新"
+		}
+		return verb + " " + target
+	}
+
+	if category == categoryTest {
+		if status == "A" {
+			return "add cases for " + target
+		}
+		return "update cases for " + target
+	}
+	if status == "R" && len(files) == 1 {
+		return fmt.Sprintf("rename %s to %s", files[0].OldPath, files[0].Path)
+	}
+	verbs := map[string]string{"A": "add", "D": "remove", "R": "rename"}
+	verb, ok := verbs[status]
+	if !ok {
+		verb = "update"
+	}
+	return verb + " " + target
+}
+
+// describeTargets 以文件名概括变更对象，最多列出两个，更多时给出文件数量
+func describeTargets(files []utils.StagedFile, locale string) string {
+	seen := map[string]bool{}
+	var names []string
+	for _, file := range files {
+		name := displayName(file.Path)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	and := " and "
+	if locale == "zh" {
+		and = " 和 "
+	}
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + and + names[1]
+	}
+	if locale == "zh" {
+		return fmt.Sprintf("%d 个文件", len(files))
+	}
+	return fmt.Sprintf("%d files", len(files))
+}
+
+// displayName 返回去掉扩展名和测试后缀的文件名，如 parser_test.go 显示为 parser
+func displayName(file string) string {
+	base := path.Base(file)
+	if buildFiles[strings.ToLower(base)] {
+		return base
+	}
+	name := strings.TrimSuffix(base, path.Ext(base))
+	for _, suffix := range []string{"_test", ".test", ".spec"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	name = strings.TrimPrefix(name, "test_")
+	if name == "" {
+		return base
+	}
+	return name
+}
+
+// Body 生成列出变更文件及行数的正文，规则生成无法解释改动原因，仅供 body: always 时使用
+func Body(files []utils.StagedFile, locale string) string {
+	title := "Changed files:"
+	if locale == "zh" {
+		title = "变更文件："
+	}
+	lines := []string{title}
+	for _, file := range files {
+		name := file.Path
+		if file.OldPath != "" {
+			name = file.OldPath + " -> " + file.Path
+		}
+		if file.Added < 0 {
+			lines = append(lines, fmt.Sprintf("- %s %s (binary)", file.Status, name))
+		} else {
+			lines = append(lines, fmt.Sprintf("- %s %s (+%d -%d)", file.Status, name, file.Added, file.Deleted))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,201 @@
+package openai_api
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/utils"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// budgetReserveTokens 为消息格式开销和估算误差预留的 tokens
+const budgetReserveTokens = 256
+
+// diffTruncatedMarker 截断 diff 时附加的说明
+const diffTruncatedMarker = "\n... (diff truncated to fit the model context window)\n"
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*tiktoken.Tiktoken{}
+)
+
+func init() {
+	// 使用内置的 BPE 词表，避免运行时联网下载
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// tiktokenEncodingName 返回 OpenAI 模型对应的 BPE 编码名称，非 OpenAI 模型返回空字符串
+func tiktokenEncodingName(model string) string {
+	name := constants.NormalizeModelName(model)
+	switch {
+	case strings.HasPrefix(name, "gpt-4o"), strings.HasPrefix(name, "gpt-4.1"),
+		strings.HasPrefix(name, "gpt-4.5"), strings.HasPrefix(name, "gpt-5"),
+		strings.HasPrefix(name, "chatgpt-"), strings.HasPrefix(name, "o1"),
+		strings.HasPrefix(name, "o3"), strings.HasPrefix(name, "o4"):
+		return tiktoken.MODEL_O200K_BASE
+	case strings.HasPrefix(name, "gpt-4"), strings.HasPrefix(name, "gpt-3.5"):
+		return tiktoken.MODEL_CL100K_BASE
+	}
+	return ""
+}
+
+// encodingFor 获取并缓存模型对应的 BPE 编码器
+func encodingFor(model string) *tiktoken.Tiktoken {
+	name := tiktokenEncodingName(model)
+	if name == "" {
+		return nil
+	}
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if enc, ok := encodings[name]; ok {
+		return enc
+	}
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		enc = nil
+	}
+	encodings[name] = enc
+	return enc
+}
+
+// CountTokens 估算文本在指定模型下的 token 数
+// OpenAI 模型使用与 tiktoken 一致的 BPE 编码精确计算，其他模型使用启发式估算：
+// ASCII 字符约 4 个一个 token，其余字符（如中文）按每个字符一个 token 计算
+func CountTokens(model, text string) int {
+	if enc := encodingFor(model); enc != nil {
+		return len(enc.EncodeOrdinary(text))
+	}
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// contextWindow 返回模型可用的上下文窗口大小，配置优先于内置表
+func contextWindow(model string, configured int) int {
+	if configured > 0 {
+		return configured
+	}
+	return constants.GetModelContextWindow(model)
+}
+
+// diffBudget 计算 diff 可用的 token 数：上下文窗口减去提示词其余部分、响应预留和安全余量
+func diffBudget(window, promptTokens, responseTokens int) int {
+	return window - promptTokens - responseTokens - budgetReserveTokens
+}
+
+// budgetDiff 按模型上下文窗口裁剪 diff，发生裁剪时向 out 输出提示
+func budgetDiff(cfg *config.Config, utilsConfig *utils.Config, data PromptData, out io.Writer) (string, error) {
+	diff := data.Diff
+	data.Diff = ""
+	messages, err := buildMessages(utilsConfig, data)
+	if err != nil {
+		return "", err
+	}
+	window := contextWindow(cfg.OpenAIModel, cfg.ContextWindow)
+	promptTokens := CountTokens(cfg.OpenAIModel, messagesText(messages))
+	budget := diffBudget(window, promptTokens, maxTokens(cfg))
+	if budget <= 0 {
+		budget = 0
+	}
+
+	fitted, truncated := fitDiffToBudget(cfg.OpenAIModel, diff, budget)
+	if truncated && out != nil {
+		fmt.Fprintf(out, "（diff 约 %d tokens，超出模型 %s 的上下文窗口 %d tokens，已截断至约 %d tokens）\n",
+			CountTokens(cfg.OpenAIModel, diff), cfg.OpenAIModel, window, budget)
+	}
+	return fitted, nil
+}
+
+// splitDiffFiles 按文件拆分 git diff，每段以 "diff --git" 开头
+func splitDiffFiles(diff string) []string {
+	var files []string
+	lines := strings.SplitAfter(diff, "\n")
+	var current strings.Builder
+	for _, line := range lines {
+		if strings.HasPrefix(line, "diff --git") && current.Len() > 0 {
+			files = append(files, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		files = append(files, current.String())
+	}
+	return files
+}
+
+// fitDiffToBudget 将 diff 裁剪到不超过 budget 个 token
+// 按文件顺序完整保留能放下的文件；放不下的文件逐行截断；剩余文件只保留文件头，让模型仍能知道改动范围
+// 返回裁剪后的 diff 以及是否发生了裁剪
+func fitDiffToBudget(model, diff string, budget int) (string, bool) {
+	if CountTokens(model, diff) <= budget {
+		return diff, false
+	}
+
+	files := splitDiffFiles(diff)
+	// omittedTokens[i] 为第 i 个文件之后所有文件头占用的 tokens，截断时为其预留空间
+	omittedTokens := make([]int, len(files)+1)
+	for i := len(files) - 1; i >= 0; i-- {
+		omittedTokens[i] = omittedTokens[i+1] + CountTokens(model, omittedLine(files[i]))
+	}
+
+	remaining := budget - CountTokens(model, diffTruncatedMarker)
+	var result strings.Builder
+	i := 0
+	for ; i < len(files); i++ {
+		tokens := CountTokens(model, files[i])
+		if tokens <= remaining-omittedTokens[i+1] {
+			result.WriteString(files[i])
+			remaining -= tokens
+			continue
+		}
+		// 逐行截断当前文件
+		for _, line := range strings.SplitAfter(files[i], "\n") {
+			lineTokens := CountTokens(model, line)
+			if lineTokens > remaining-omittedTokens[i+1] {
+				break
+			}
+			result.WriteString(line)
+			remaining -= lineTokens
+		}
+		i++
+		break
+	}
+
+	result.WriteString(diffTruncatedMarker)
+	for ; i < len(files); i++ {
+		line := omittedLine(files[i])
+		tokens := CountTokens(model, line)
+		if tokens > remaining {
+			break
+		}
+		result.WriteString(line)
+		remaining -= tokens
+	}
+	return result.String(), true
+}
+
+// omittedLine 返回被省略文件的说明行
+func omittedLine(file string) string {
+	return fileHeader(file) + " (omitted)\n"
+}
+
+// fileHeader 返回单个文件 diff 的首行（diff --git a/x b/x）
+func fileHeader(file string) string {
+	if i := strings.Index(file, "\n"); i >= 0 {
+		return file[:i]
+	}
+	return file
+}
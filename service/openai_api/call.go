@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/feiandxs/agcommits/service/commitstyle"
 	"github.com/feiandxs/agcommits/utils" // 导入 utils 包以使用 Config 结构体
 	"github.com/sashabaranov/go-openai"
 )
@@ -25,19 +26,19 @@ func ChatCompletionBlocking(config *utils.Config, diff string) (string, error) {
 	generateClient(config)
 
 	// 构建提示词
-	prompt := generatePrompt(config, diff)
+	data := collectPromptData(config, diff)
+	data.setStyle(commitstyle.Get(config.CommitType))
+	messages, err := buildMessages(config, data)
+	if err != nil {
+		return "", err
+	}
 
 	resp, err := client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			MaxTokens: config.MaxLength,
+			Model:     model,
+			Messages:  messages,
+			MaxTokens: config.MaxTokens,
 		})
 	if err != nil {
 		fmt.Printf("completion error: %v\n", err)
@@ -2,9 +2,20 @@ package openai_api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/breaking"
+	"github.com/feiandxs/agcommits/service/cache"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+	"github.com/feiandxs/agcommits/service/usage"
 	"github.com/feiandxs/agcommits/utils"
 	"github.com/sashabaranov/go-openai"
 )
@@ -12,50 +23,570 @@ import (
 // 将config.Config转换为utils.Config
 func convertConfig(cfg *config.Config) *utils.Config {
 	return &utils.Config{
-		OpenAIKey:    cfg.OpenAIKey,
-		OpenAPIBase:  cfg.OpenAPIBase,
-		OpenAIModel:  cfg.OpenAIModel,
-		CommitLocale: cfg.CommitLocale,
-		MaxLength:    cfg.MaxLength,
-		CommitType:   cfg.CommitType,
+		OpenAIKey:         cfg.OpenAIKey,
+		OpenAPIBase:       cfg.OpenAPIBase,
+		OpenAIModel:       cfg.OpenAIModel,
+		CommitLocale:      cfg.CommitLocale,
+		MaxLength:         subjectLength(cfg),
+		MaxTokens:         maxTokens(cfg),
+		CommitType:        cfg.CommitType,
+		Body:              bodyMode(cfg),
+		OutputFormat:      cfg.OutputFormat,
+		PromptTemplate:    cfg.PromptTemplate,
+		MergeSystemPrompt: cfg.MergeSystemPrompt,
 	}
 }
 
-// GenerateCommitMessage 使用 OpenAI API 生成提交信息
-func GenerateCommitMessage(cfg *config.Config, diff string) (string, error) {
-	client := openai.NewClient(cfg.OpenAIKey)
+// newClient 根据配置创建 OpenAI 客户端，支持自定义 API 端点
+// 采样参数和自定义字段通过 bodyFieldsTransport 写入请求体，代理、CA 证书和附加请求头作用于底层传输层
+func newClient(cfg *config.Config) (*openai.Client, error) {
+	transport, err := newNetworkTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.ExtraHeaders) > 0 {
+		transport = &headerTransport{base: transport, headers: cfg.ExtraHeaders}
+	}
+
+	config := openai.DefaultConfig(cfg.OpenAIKey)
 	if cfg.OpenAPIBase != "" {
-		config := openai.DefaultConfig(cfg.OpenAIKey)
 		config.BaseURL = cfg.OpenAPIBase
-		client = openai.NewClientWithConfig(config)
 	}
+	config.HTTPClient = &http.Client{
+		Transport: &bodyFieldsTransport{
+			base:   transport,
+			fields: requestBodyFields(cfg),
+		},
+	}
+	return openai.NewClientWithConfig(config), nil
+}
+
+// maxTokens 返回 AI 响应的最大 token 数，未配置时使用默认值
+func maxTokens(cfg *config.Config) int {
+	if cfg.MaxTokens <= 0 {
+		return constants.DefaultMaxTokens
+	}
+	return cfg.MaxTokens
+}
+
+// requestTimeout 返回单次 AI 请求的超时时间，未配置时使用默认值
+func requestTimeout(cfg *config.Config) time.Duration {
+	timeout := cfg.RequestTimeout
+	if timeout <= 0 {
+		timeout = constants.DefaultRequestTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// GenerateOptions 控制单次生成行为的选项
+type GenerateOptions struct {
+	// Out 接收流式生成的增量内容，为 nil 时不输出
+	Out io.Writer
+
+	// NoCache 为 true 时跳过缓存读取，强制重新请求 AI
+	NoCache bool
+
+	// Hint 用户对本次改动意图的说明，作为参考写入提示词，为空时不使用
+	Hint string
+}
+
+// openResponseCache 按配置创建响应缓存，未配置时使用默认的有效期和容量
+func openResponseCache(cfg *config.Config) (*cache.Cache, error) {
+	ttl := cfg.CacheTTL
+	if ttl <= 0 {
+		ttl = constants.DefaultCacheTTL
+	}
+	maxEntries := cfg.CacheMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = constants.DefaultCacheMaxEntries
+	}
+	return cache.New(time.Duration(ttl)*time.Minute, maxEntries)
+}
 
+// cacheKey 根据 API 地址和实际发送的请求体计算缓存键，提示词、模型及请求参数任一变化都会得到不同的键
+func cacheKey(cfg *config.Config, request openai.ChatCompletionRequest) string {
+	body, _ := json.Marshal(request)
+	if merged, err := mergeBodyFields(body, requestBodyFields(cfg)); err == nil {
+		body = merged
+	}
+	return cache.Key(cfg.OpenAPIBase, string(body))
+}
+
+// enforceSpendingBudget 检查当前服务的用量预算
+// 接近上限时输出警告；超出上限时若配置了备用模型则返回切换后的配置，否则返回 usage.ErrBudgetExceeded
+func enforceSpendingBudget(cfg *config.Config, out io.Writer) (*config.Config, error) {
+	status, err := usage.CheckBudget(cfg.Budget, cfg.OpenAPIBase, cfg.ModelPrices, cfg.PriceCurrency)
+	if err != nil {
+		// 用量记录读取失败时不阻止生成
+		return cfg, nil
+	}
+	if out != nil {
+		for _, warning := range status.Warnings {
+			fmt.Fprintf(out, "（警告：%s，即将达到预算上限）\n", warning)
+		}
+	}
+	if !status.Exceeded {
+		return cfg, nil
+	}
+
+	reasons := strings.Join(status.Reasons, "；")
+	if cfg.Budget.FallbackModel == "" {
+		return nil, fmt.Errorf("%w: %s", usage.ErrBudgetExceeded, reasons)
+	}
+
+	fallback := *cfg
+	fallback.OpenAIModel = cfg.Budget.FallbackModel
+	if cfg.Budget.FallbackAPIBase != "" {
+		fallback.OpenAPIBase = cfg.Budget.FallbackAPIBase
+	}
+	if cfg.Budget.FallbackKey != "" {
+		fallback.OpenAIKey = cfg.Budget.FallbackKey
+	}
+	// 备用模型不再受原服务的预算限制
+	fallback.Budget = config.Budget{}
+	// merge_system_prompt 针对主服务商的模型，备用模型使用默认的 system 消息
+	fallback.MergeSystemPrompt = false
+	if out != nil {
+		fmt.Fprintf(out, "（%s，已超出预算上限，改用备用模型 %s）\n", reasons, fallback.OpenAIModel)
+	}
+	return &fallback, nil
+}
+
+// GenerateCommitMessage 使用 OpenAI API 以流式方式生成一条提交信息，参见 GenerateCommitMessages
+func GenerateCommitMessage(ctx context.Context, cfg *config.Config, diff string, opts GenerateOptions) (string, error) {
+	messages, err := GenerateCommitMessages(ctx, cfg, diff, opts)
+	if err != nil {
+		return "", err
+	}
+	return messages[0], nil
+}
+
+// GenerateCommitMessages 使用 OpenAI API 以流式方式生成候选提交信息，返回去重后的列表（至少一条）
+// 生成过程中第一条候选的增量内容会实时写入 opts.Out
+// 配置 candidates 大于 1 时通过 n 参数一次请求多条候选，服务商不支持 n 时改为并发请求补足
+// 相同的提示词、模型和参数在缓存有效期内直接返回缓存结果，不会重复请求，也不受用量预算限制
+// ctx 被取消（如用户按下 Ctrl-C）或超时后会中止 HTTP 请求，返回的错误可用 errors.Is 判断
+func GenerateCommitMessages(ctx context.Context, cfg *config.Config, diff string, opts GenerateOptions) ([]string, error) {
+	request, prompt, style, err := prepareRequest(cfg, diff, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 缓存不可用时不影响生成，仅跳过缓存
+	responseCache, cacheErr := openResponseCache(cfg)
+	key := cacheKey(cfg, request)
+	if messages, ok := cachedMessages(responseCache, cacheErr, key, opts); ok {
+		return messages, nil
+	}
+
+	// 缓存未命中、需要请求服务商时才检查用量预算，超出时切换到备用模型或拒绝调用
+	budgetCfg, err := enforceSpendingBudget(cfg, opts.Out)
+	if err != nil {
+		return nil, err
+	}
+	if budgetCfg != cfg {
+		// 备用模型的上下文窗口和缓存键不同，按备用模型重新构建请求
+		cfg = budgetCfg
+		request, prompt, style, err = prepareRequest(cfg, diff, opts)
+		if err != nil {
+			return nil, err
+		}
+		key = cacheKey(cfg, request)
+		if messages, ok := cachedMessages(responseCache, cacheErr, key, opts); ok {
+			return messages, nil
+		}
+	}
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	count := candidateCount(cfg)
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout(cfg))
+	defer cancel()
+
+	if count > 1 && opts.Out != nil {
+		fmt.Fprintf(opts.Out, "（正在生成 %d 条候选提交消息，实时显示第 1 条）\n", count)
+	}
+	// 请求在流末尾返回用量；多数不支持该选项的服务商会忽略它，拒绝该选项时去掉后重试
+	streamRequest := request
+	streamRequest.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, request, err := createStream(ctx, client, streamRequest)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI API 调用失败: %w", err)
+	}
+	defer stream.Close()
+
+	contents, tokenUsage, err := readStream(ctx, stream, opts.Out)
+	if err != nil {
+		return nil, err
+	}
+	recordUsage(cfg, prompt, strings.Join(contents, "\n"), tokenUsage)
+
+	// 服务商忽略或拒绝了 n 参数时，并发请求补足剩余的候选
+	if missing := count - len(contents); missing > 0 {
+		single := request
+		single.N = 0
+		extra, err := requestCandidates(ctx, client, cfg, single, prompt, missing)
+		if err != nil && ctx.Err() != nil {
+			return nil, fmt.Errorf("OpenAI API 调用失败: %w", ctx.Err())
+		}
+		contents = append(contents, extra...)
+	}
+
+	messages := finalizeMessages(cfg, style, contents, opts.Out)
+	valid, invalidErr := validMessages(style, messages)
+	// 全部候选都不符合提交格式时，附上纠正说明要求模型重新生成
+	for attempt := 0; len(valid) == 0 && attempt < constants.DefaultRegenerateAttempts; attempt++ {
+		if opts.Out != nil {
+			fmt.Fprintf(opts.Out, "（提交消息不符合 %s 格式：%v，正在重新生成）\n", style.Name, invalidErr)
+		}
+		retry := request
+		retry.N = 0
+		retry.Messages = append(append([]openai.ChatCompletionMessage{}, request.Messages...),
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: messages[0]},
+			openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: style.Correction()},
+		)
+		contents, err := requestCandidates(ctx, client, cfg, retry, prompt, 1)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("OpenAI API 调用失败: %w", ctx.Err())
+			}
+			break
+		}
+		messages = finalizeMessages(cfg, style, contents, opts.Out)
+		valid, invalidErr = validMessages(style, messages)
+	}
+	if len(valid) == 0 {
+		// 不符合格式的消息交给用户确认，但不写入缓存，下次仍会重新生成并检查格式
+		if opts.Out != nil {
+			fmt.Fprintf(opts.Out, "（提交消息仍不符合 %s 格式：%v，请确认后再提交）\n", style.Name, invalidErr)
+		}
+		return messages, nil
+	}
+	if invalidErr != nil && opts.Out != nil {
+		fmt.Fprintf(opts.Out, "（已丢弃 %d 条不符合 %s 格式的候选：%v）\n", len(messages)-len(valid), style.Name, invalidErr)
+	}
+	if cacheErr == nil {
+		responseCache.Put(key, valid)
+	}
+	return valid, nil
+}
+
+// prepareRequest 收集提示词数据并构建请求，返回请求、用于统计用量的提示词文本和提交格式
+func prepareRequest(cfg *config.Config, diff string, opts GenerateOptions) (openai.ChatCompletionRequest, string, commitstyle.Style, error) {
 	// 将config.Config转换为utils.Config
 	utilsConfig := convertConfig(cfg)
+	data := collectPromptData(utilsConfig, diff)
+	data.Hint = strings.TrimSpace(opts.Hint)
+	data.StyleExamples = styleExamples(cfg, data.Files)
+	style := CommitStyle(cfg, data.Files)
+	data.setStyle(style)
+	if cfg.Context.RelatedCommits {
+		data.RelatedCommits = relatedCommits(data.Files)
+	}
+	if cfg.Context.Readme {
+		data.Description = projectDescription()
+	}
+	var err error
+	// 仓库上下文只占用部分剩余空间，优先保证 diff
+	data.Context, err = budgetContext(cfg, utilsConfig, data, opts.Out)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, "", style, err
+	}
+	// 按模型上下文窗口裁剪 diff，避免请求超出限制
+	data.Diff, err = budgetDiff(cfg, utilsConfig, data, opts.Out)
+	if err != nil {
+		return openai.ChatCompletionRequest{}, "", style, err
+	}
 	// 构建提示词
-	prompt := generatePrompt(utilsConfig, diff)
-
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: cfg.OpenAIModel,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			MaxTokens: cfg.MaxLength,
-		},
-	)
-
+	chatMessages, err := buildMessages(utilsConfig, data)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI API 调用失败: %v", err)
+		return openai.ChatCompletionRequest{}, "", style, err
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:          cfg.OpenAIModel,
+		Messages:       chatMessages,
+		MaxTokens:      maxTokens(cfg),
+		Seed:           cfg.Seed,
+		Stop:           cfg.Stop,
+		ResponseFormat: responseFormat(cfg.OutputFormat),
+	}
+	if count := candidateCount(cfg); count > 1 {
+		request.N = count
+	}
+	return adaptRequestForModel(cfg, request), messagesText(chatMessages), style, nil
+}
+
+// cachedMessages 在未跳过缓存时查找缓存结果，命中时输出提示
+func cachedMessages(responseCache *cache.Cache, cacheErr error, key string, opts GenerateOptions) ([]string, bool) {
+	if cacheErr != nil || opts.NoCache {
+		return nil, false
+	}
+	messages, ok := responseCache.Get(key)
+	if ok && opts.Out != nil {
+		fmt.Fprintln(opts.Out, "（使用缓存结果，可通过 --no-cache 重新生成）")
+		fmt.Fprintln(opts.Out, messages[0])
+	}
+	return messages, ok
+}
+
+// createStream 发起流式请求，返回实际发送的请求
+// 服务商以 4xx 拒绝 stream_options 或 n 参数时依次去掉后重试：没有 stream_options 时按本地估算记录用量，
+// 没有 n 时剩余的候选由调用方并发请求补足
+func createStream(ctx context.Context, client *openai.Client, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, openai.ChatCompletionRequest, error) {
+	stream, err := client.CreateChatCompletionStream(ctx, request)
+	if err != nil && request.StreamOptions != nil && isRejectedRequest(err) {
+		request.StreamOptions = nil
+		stream, err = client.CreateChatCompletionStream(ctx, request)
+	}
+	if err != nil && request.N > 1 && isRejectedRequest(err) {
+		request.N = 0
+		stream, err = client.CreateChatCompletionStream(ctx, request)
+	}
+	return stream, request, err
+}
+
+// isRejectedRequest 判断请求是否因参数不被支持而被服务商拒绝（4xx），鉴权失败、限流等错误除外
+func isRejectedRequest(err error) bool {
+	status := 0
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &requestErr):
+		status = requestErr.HTTPStatusCode
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+// candidateCount 返回每次生成的候选提交消息数量，至少为 1
+func candidateCount(cfg *config.Config) int {
+	if cfg.Candidates < 1 {
+		return 1
+	}
+	return cfg.Candidates
+}
+
+// requestCandidates 并发发起 count 个非流式请求，返回成功得到的内容
+// 部分请求失败时仍返回其余结果，全部失败时返回最后一个错误
+func requestCandidates(ctx context.Context, client *openai.Client, cfg *config.Config, request openai.ChatCompletionRequest, prompt string, count int) ([]string, error) {
+	type result struct {
+		content string
+		err     error
+	}
+	results := make(chan result, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			resp, err := client.CreateChatCompletion(ctx, request)
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			if len(resp.Choices) == 0 {
+				results <- result{err: fmt.Errorf("OpenAI API 返回结果为空")}
+				return
+			}
+			recordUsage(cfg, prompt, resp.Choices[0].Message.Content, &resp.Usage)
+			// 推理模型的思考过程不属于提交消息
+			content := stripReasoning(resp.Choices[0].Message.Content)
+			if content == "" {
+				results <- result{err: fmt.Errorf("OpenAI API 返回结果为空")}
+				return
+			}
+			results <- result{content: content}
+		}()
+	}
+
+	var contents []string
+	var lastErr error
+	for i := 0; i < count; i++ {
+		r := <-results
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		contents = append(contents, r.content)
 	}
+	if len(contents) == 0 {
+		return nil, lastErr
+	}
+	return contents, nil
+}
+
+// finalizeMessage 将模型返回的原始内容处理为最终的提交消息
+func finalizeMessage(cfg *config.Config, style commitstyle.Style, content string, out io.Writer) string {
+	message := content
+	// 结构化输出模式下由程序按提交格式渲染，解析失败时退回使用原始文本
+	if isStructuredOutput(cfg.OutputFormat) {
+		if commit, err := parseStructuredCommit(message); err == nil {
+			message = style.Render(commitstyle.Commit(commit))
+		} else if out != nil {
+			fmt.Fprintf(out, "（%v，使用原始响应作为提交消息）\n", err)
+		}
+	} else {
+		// 模型省略 scope 时使用根据变更路径推断的 scope
+		message = style.ApplyScope(message)
+	}
+	// 检测到破坏性变更而模型未标记时补上标记
+	message = style.ApplyBreaking(message)
+
+	message = formatBody(message, bodyMode(cfg))
 
-	if len(resp.Choices) == 0 {
-		return "", fmt.Errorf("OpenAI API 返回结果为空")
+	// 按字符数（而非 token 数）限制提交消息标题长度
+	if limited, truncated := enforceMaxLength(message, subjectLength(cfg)); truncated {
+		if out != nil {
+			fmt.Fprintf(out, "（提交消息标题超过 %d 个字符，已截断）\n", subjectLength(cfg))
+		}
+		message = limited
 	}
+	return message
+}
+
+// finalizeMessages 将模型返回的全部内容处理为去重后的候选提交消息
+func finalizeMessages(cfg *config.Config, style commitstyle.Style, contents []string, out io.Writer) []string {
+	var messages []string
+	for _, content := range contents {
+		messages = append(messages, finalizeMessage(cfg, style, content, out))
+	}
+	return dedupeMessages(messages)
+}
 
-	return resp.Choices[0].Message.Content, nil
+// validMessages 返回符合提交格式的候选，以及最后一条不符合格式的原因
+func validMessages(style commitstyle.Style, messages []string) ([]string, error) {
+	var valid []string
+	var lastErr error
+	for _, message := range messages {
+		if err := style.Validate(message); err != nil {
+			lastErr = err
+			continue
+		}
+		valid = append(valid, message)
+	}
+	return valid, lastErr
+}
+
+// CommitStyle 返回配置的提交格式，项目定义了类型和 scope 时替换内置列表，并根据暂存区的文件推断 scope、按 breaking_detection 检测破坏性变更
+func CommitStyle(cfg *config.Config, files []utils.StagedFile) commitstyle.Style {
+	var types []commitstyle.Type
+	for _, t := range cfg.Types {
+		types = append(types, commitstyle.Type{Name: t.Name, Description: t.Description})
+	}
+	style := commitstyle.Get(cfg.CommitType).Customize(types, cfg.Scopes).WithScope(inferScope(cfg, files))
+	switch cfg.BreakingDetection {
+	case constants.BreakingDetectionOff:
+		return style
+	case constants.BreakingDetectionPrompt:
+		return style.WithBreaking(breaking.Detect(files), false)
+	}
+	return style.WithBreaking(breaking.Detect(files), true)
+}
+
+// inferScope 根据暂存区的文件路径和 scope_map 推断 scope
+func inferScope(cfg *config.Config, files []utils.StagedFile) string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	repoRoot, _ := utils.GetRepoRoot()
+	return commitstyle.InferScope(paths, cfg.ScopeMap, repoRoot)
+}
+
+// dedupeMessages 去掉重复的候选消息（忽略首尾空白和大小写），保持原有顺序
+func dedupeMessages(messages []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, message := range messages {
+		normalized := strings.ToLower(strings.TrimSpace(message))
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		result = append(result, message)
+	}
+	return result
+}
+
+// readStream 逐块读取流式响应，按候选序号拼接各条内容，只实时输出第一条候选的增量内容
+// 推理模型的思考过程（<think> 块及 reasoning_content 字段）不会输出，也不会计入结果
+// 服务商在流末尾返回用量时一并返回，否则用量为 nil
+func readStream(ctx context.Context, stream *openai.ChatCompletionStream, out io.Writer) ([]string, *openai.Usage, error) {
+	var builders []*strings.Builder
+	var tokenUsage *openai.Usage
+	var filter *reasoningFilter
+	if out != nil {
+		filter = &reasoningFilter{out: out}
+	}
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// 读取中途被取消时，优先返回上下文错误，便于调用方区分中断与超时
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, nil, fmt.Errorf("OpenAI API 调用失败: %w", ctxErr)
+			}
+			return nil, nil, fmt.Errorf("读取 OpenAI API 流式响应失败: %w", err)
+		}
+		if resp.Usage != nil {
+			tokenUsage = resp.Usage
+		}
+		for _, choice := range resp.Choices {
+			for len(builders) <= choice.Index {
+				builders = append(builders, &strings.Builder{})
+			}
+			builders[choice.Index].WriteString(choice.Delta.Content)
+			if choice.Index == 0 && filter != nil {
+				if choice.Delta.ReasoningContent != "" && !filter.thinking {
+					filter.thinking = true
+					fmt.Fprintln(out, "（模型思考中...）")
+				}
+				filter.Write(choice.Delta.Content)
+			}
+		}
+	}
+	if out != nil {
+		fmt.Fprintln(out)
+	}
+
+	var contents []string
+	for _, builder := range builders {
+		if content := stripReasoning(builder.String()); content != "" {
+			contents = append(contents, content)
+		}
+	}
+	if len(contents) == 0 {
+		return nil, nil, fmt.Errorf("OpenAI API 返回结果为空")
+	}
+	return contents, tokenUsage, nil
+}
+
+// recordUsage 记录本次调用的 token 用量，服务商未返回用量时按本地估算值记录
+// 记录失败不影响提交流程
+func recordUsage(cfg *config.Config, prompt, message string, tokenUsage *openai.Usage) {
+	record := usage.Record{
+		Time:    time.Now(),
+		APIBase: cfg.OpenAPIBase,
+		Model:   cfg.OpenAIModel,
+	}
+	if tokenUsage != nil {
+		record.PromptTokens = tokenUsage.PromptTokens
+		record.CompletionTokens = tokenUsage.CompletionTokens
+	} else {
+		record.PromptTokens = CountTokens(cfg.OpenAIModel, prompt)
+		record.CompletionTokens = CountTokens(cfg.OpenAIModel, message)
+		record.Estimated = true
+	}
+	if repo, err := utils.GetRepoRoot(); err == nil {
+		record.Repo = repo
+	}
+	usage.Append(record)
 }
@@ -0,0 +1,204 @@
+package openai_api
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/utils"
+)
+
+// contextBudgetShare 仓库上下文最多占用提示词剩余空间的比例（1/contextBudgetShare），其余留给 diff
+const contextBudgetShare = 4
+
+// readmeFileNames 查找项目简介时依次尝试的 README 文件名
+var readmeFileNames = []string{"README.md", "README", "README.rst", "README.txt", "readme.md"}
+
+// repoContext 按 context 配置收集的仓库上下文
+type repoContext struct {
+	branch      string
+	files       []utils.StagedFile
+	related     []string
+	description string
+}
+
+// collectRepoContext 按 context 配置收集仓库上下文，获取失败的项留空
+func collectRepoContext(cfg *config.Config, data PromptData) repoContext {
+	var rc repoContext
+	if cfg.Context.Branch {
+		rc.branch = data.Branch
+	}
+	if cfg.Context.Files {
+		rc.files = data.Files
+	}
+	if cfg.Context.RelatedCommits {
+		rc.related = data.RelatedCommits
+	}
+	if cfg.Context.Readme {
+		rc.description = data.Description
+	}
+	return rc
+}
+
+// relatedCommits 获取最近改动过暂存文件的提交标题，新增的文件没有历史，不参与查询
+// 暂存文件的路径相对于仓库根目录，GetCommitSubjects 按仓库根目录解析，在子目录中运行时同样有效
+func relatedCommits(files []utils.StagedFile) []string {
+	var paths []string
+	for _, file := range files {
+		if file.Status == "A" {
+			continue
+		}
+		paths = append(paths, file.Path)
+		if file.OldPath != "" {
+			paths = append(paths, file.OldPath)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	subjects, _ := utils.GetCommitSubjects(constants.DefaultRelatedCommits, "", paths)
+	return subjects
+}
+
+// projectDescription 从仓库根目录的 README 中提取项目简介：跳过标题、徽章、HTML 和代码块，取第一段正文
+func projectDescription() string {
+	root, err := utils.GetRepoRoot()
+	if err != nil {
+		return ""
+	}
+	for _, name := range readmeFileNames {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+		return firstParagraph(string(content), constants.DefaultReadmeLength)
+	}
+	return ""
+}
+
+// firstParagraph 返回文本中第一段正文，超过 limit 个字符时截断
+func firstParagraph(content string, limit int) string {
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		if line == "" {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		if isReadmeDecoration(line) {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	paragraph := strings.Join(lines, " ")
+	if runes := []rune(paragraph); len(runes) > limit {
+		paragraph = string(runes[:limit]) + "..."
+	}
+	return paragraph
+}
+
+// isReadmeDecoration 判断 README 中的行是否为标题、徽章、链接、HTML 或分隔线等非正文内容
+func isReadmeDecoration(line string) bool {
+	for _, prefix := range []string{"#", "![", "[![", "<", "---", "===", "***", "|", ">"} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	// 只有一个链接的行，如 [中文文档](./README_ZH_CN.md)
+	return strings.HasPrefix(line, "[") && strings.HasSuffix(line, ")") && strings.Count(line, "](") == 1
+}
+
+// render 将仓库上下文渲染为提示词中的文本，没有任何内容时返回空字符串
+func (rc repoContext) render() string {
+	var sections []string
+	if rc.branch != "" {
+		sections = append(sections, "Branch: "+rc.branch)
+	}
+	if len(rc.files) > 0 {
+		var builder strings.Builder
+		builder.WriteString("Staged files:")
+		for _, file := range rc.files {
+			builder.WriteString("\n" + file.Status + " " + file.Path)
+			if file.OldPath != "" {
+				builder.WriteString(" (from " + file.OldPath + ")")
+			}
+		}
+		sections = append(sections, builder.String())
+	}
+	if len(rc.related) > 0 {
+		sections = append(sections, "Recent commits touching these files:\n- "+strings.Join(rc.related, "\n- "))
+	}
+	if rc.description != "" {
+		sections = append(sections, "Project description: "+rc.description)
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// fitRepoContext 将仓库上下文裁剪到不超过 budget 个 token
+// 依次去掉项目简介、相关提交，再逐步减半文件列表，最后去掉分支名；返回渲染结果以及是否发生了裁剪
+func fitRepoContext(model string, rc repoContext, budget int) (string, bool) {
+	truncated := false
+	for {
+		text := rc.render()
+		if text == "" || CountTokens(model, text) <= budget {
+			return text, truncated
+		}
+		truncated = true
+		switch {
+		case rc.description != "":
+			rc.description = ""
+		case len(rc.related) > 0:
+			rc.related = nil
+		case len(rc.files) > 1:
+			rc.files = rc.files[:len(rc.files)/2]
+		case len(rc.files) == 1:
+			rc.files = nil
+		default:
+			rc.branch = ""
+		}
+	}
+}
+
+// budgetContext 按 context 配置渲染仓库上下文，并限制其最多占用提示词剩余空间的 1/contextBudgetShare
+// 发生裁剪时向 out 输出提示
+func budgetContext(cfg *config.Config, utilsConfig *utils.Config, data PromptData, out io.Writer) (string, error) {
+	rc := collectRepoContext(cfg, data)
+	if rc.render() == "" {
+		return "", nil
+	}
+	data.Diff = ""
+	data.Context = ""
+	messages, err := buildMessages(utilsConfig, data)
+	if err != nil {
+		return "", err
+	}
+	window := contextWindow(cfg.OpenAIModel, cfg.ContextWindow)
+	promptTokens := CountTokens(cfg.OpenAIModel, messagesText(messages))
+	budget := diffBudget(window, promptTokens, maxTokens(cfg)) / contextBudgetShare
+	if budget < 0 {
+		budget = 0
+	}
+
+	text, truncated := fitRepoContext(cfg.OpenAIModel, rc, budget)
+	if truncated && out != nil {
+		fmt.Fprintf(out, "（仓库上下文超出可用空间，已裁剪至约 %d tokens）\n", budget)
+	}
+	return text, nil
+}
@@ -0,0 +1,52 @@
+package openai_api
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/feiandxs/agcommits/config"
+)
+
+// nonChatModelKeywords 出现在模型名称中即视为非对话模型的关键字
+var nonChatModelKeywords = []string{
+	"embed", "embedding", "bge-", "rerank", "whisper", "tts", "speech", "audio",
+	"transcribe", "dall-e", "image", "stable-diffusion", "flux", "kolors",
+	"moderation", "davinci-002", "babbage-002", "sora", "video",
+}
+
+// isChatModel 根据模型名称判断是否为可用于对话补全的模型
+func isChatModel(id string) bool {
+	name := strings.ToLower(id)
+	for _, keyword := range nonChatModelKeywords {
+		if strings.Contains(name, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListChatModels 调用服务商的模型列表接口，返回按名称排序的对话模型
+func ListChatModels(ctx context.Context, cfg *config.Config) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout(cfg))
+	defer cancel()
+
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取模型列表失败: %w", err)
+	}
+
+	var models []string
+	for _, model := range list.Models {
+		if isChatModel(model.ID) {
+			models = append(models, model.ID)
+		}
+	}
+	sort.Strings(models)
+	return models, nil
+}
@@ -0,0 +1,226 @@
+package openai_api
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+)
+
+// enforceMaxLength 将提交消息的首行（标题）限制在 maxLength 个字符（按 Unicode 字符计，中文与英文字母均计为 1）以内
+// 截断时优先在靠后的空白处断开，避免截断英文单词，并去掉末尾多余的空白和标点；正文和脚注保持不变
+// maxLength 不大于 0 时不做限制
+func enforceMaxLength(message string, maxLength int) (string, bool) {
+	header, rest, hasRest := strings.Cut(message, "\n")
+	limited, truncated := truncateLine(header, maxLength)
+	if hasRest {
+		limited += "\n" + rest
+	}
+	return limited, truncated
+}
+
+// truncateLine 将单行文本截断到 maxLength 个字符以内
+func truncateLine(line string, maxLength int) (string, bool) {
+	runes := []rune(line)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return line, false
+	}
+
+	cut := runes[:maxLength]
+	// 截断位置两侧都是英文字母或数字时说明断在单词中间，回退到最后一个空白处（只在后半段查找，避免丢失过多内容）
+	if isWordRune(runes[maxLength-1]) && isWordRune(runes[maxLength]) {
+		for i := len(cut) - 1; i >= maxLength/2; i-- {
+			if unicode.IsSpace(cut[i]) {
+				cut = cut[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(cut), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}), true
+}
+
+// isWordRune 判断是否为组成英文单词的字符
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// bodyMode 返回正文模式，未配置时不生成正文
+// 结构化输出的 JSON Schema 本身包含 body 字段，未配置时按 auto 处理，保留模型返回的正文
+func bodyMode(cfg *config.Config) string {
+	switch cfg.Body {
+	case constants.BodyAuto, constants.BodyAlways, constants.BodyOff:
+		return cfg.Body
+	}
+	if isStructuredOutput(cfg.OutputFormat) {
+		return constants.BodyAuto
+	}
+	return constants.BodyOff
+}
+
+// LimitSubject 按配置的 max_length/subject_width 限制提交消息标题长度，返回处理后的消息以及是否发生了截断
+// 用于生成后又改动了标题的场景，如在标题前加上工单编号
+func LimitSubject(cfg *config.Config, message string) (string, bool) {
+	return enforceMaxLength(message, subjectLength(cfg))
+}
+
+// subjectLength 返回提交消息标题的最大长度：启用正文时使用 subject_width（不超过 max_length），否则使用 max_length
+func subjectLength(cfg *config.Config) int {
+	if bodyMode(cfg) == constants.BodyOff {
+		return cfg.MaxLength
+	}
+	width := cfg.SubjectWidth
+	if width <= 0 {
+		width = constants.DefaultSubjectWidth
+	}
+	if cfg.MaxLength > 0 && cfg.MaxLength < width {
+		return cfg.MaxLength
+	}
+	return width
+}
+
+// bodyInstruction 返回正文模式对应的提示词要求，不生成正文时为空
+func bodyInstruction(mode string) string {
+	switch mode {
+	case constants.BodyAuto:
+		return fmt.Sprintf("If the change is not self-explanatory, add a blank line after the subject followed by a body explaining what changed and why, "+
+			"wrapped at %d columns. Omit the body for trivial changes.\n", constants.BodyWrapWidth)
+	case constants.BodyAlways:
+		return fmt.Sprintf("After the subject, add a blank line followed by a body explaining what changed and why, wrapped at %d columns.\n",
+			constants.BodyWrapWidth)
+	}
+	return ""
+}
+
+// formatBody 按正文模式整理提交消息：off 时只保留标题和脚注；否则确保标题与正文之间有空行，并将正文按宽度换行
+func formatBody(message, mode string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	header := strings.TrimSpace(lines[0])
+	rest := strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	if rest == "" {
+		return header
+	}
+
+	paragraphs := strings.Split(rest, "\n\n")
+	if mode == constants.BodyOff {
+		// 只保留全部由脚注组成的最后一段
+		last := strings.TrimSpace(paragraphs[len(paragraphs)-1])
+		if isFooterParagraph(last) {
+			return header + "\n\n" + last
+		}
+		return header
+	}
+
+	var formatted []string
+	for _, paragraph := range paragraphs {
+		if paragraph = strings.TrimSpace(paragraph); paragraph == "" {
+			continue
+		}
+		var wrapped []string
+		for _, line := range strings.Split(paragraph, "\n") {
+			wrapped = append(wrapped, wrapLine(strings.TrimRight(line, " \t"), constants.BodyWrapWidth)...)
+		}
+		formatted = append(formatted, strings.Join(wrapped, "\n"))
+	}
+	return header + "\n\n" + strings.Join(formatted, "\n\n")
+}
+
+// isFooterParagraph 判断段落是否全部由脚注行组成
+func isFooterParagraph(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !commitstyle.IsFooterLine(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapLine 将一行正文按显示宽度换行：英文在空白处断开，中日韩文字可在任意字符间断开
+// 列表项（以 "- " 或 "* " 开头）的续行缩进两个空格，脚注行保持不变
+func wrapLine(line string, width int) []string {
+	if commitstyle.IsFooterLine(line) || displayWidth(line) <= width {
+		return []string{line}
+	}
+
+	indent := ""
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
+		indent = "  "
+	}
+
+	var lines []string
+	var current strings.Builder
+	currentWidth := 0
+	for _, unit := range wrapUnits(line) {
+		text := unit.text
+		if unit.space && current.Len() > 0 {
+			text = " " + text
+		}
+		if currentWidth > 0 && currentWidth+displayWidth(text) > width {
+			lines = append(lines, current.String())
+			current.Reset()
+			current.WriteString(indent)
+			text = unit.text
+			currentWidth = len(indent)
+		}
+		current.WriteString(text)
+		currentWidth += displayWidth(text)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// wrapUnit 换行时不可拆分的最小单位，space 表示前面原本有空白
+type wrapUnit struct {
+	text  string
+	space bool
+}
+
+// wrapUnits 将一行拆分为单词和单个宽字符
+func wrapUnits(line string) []wrapUnit {
+	var units []wrapUnit
+	for i, word := range strings.Fields(line) {
+		space := i > 0
+		var narrow strings.Builder
+		for _, r := range word {
+			if runeWidth(r) == 2 {
+				if narrow.Len() > 0 {
+					units = append(units, wrapUnit{text: narrow.String(), space: space})
+					narrow.Reset()
+					space = false
+				}
+				units = append(units, wrapUnit{text: string(r), space: space})
+				space = false
+				continue
+			}
+			narrow.WriteRune(r)
+		}
+		if narrow.Len() > 0 {
+			units = append(units, wrapUnit{text: narrow.String(), space: space})
+		}
+	}
+	return units
+}
+
+// displayWidth 返回文本在终端中的显示宽度
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// runeWidth 中日韩文字和全角符号宽度为 2，其余为 1
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF00 && r <= 0xFF60) || (r >= 0xFFE0 && r <= 0xFFE6) {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,145 @@
+package openai_api
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/sashabaranov/go-openai"
+)
+
+// reasoningBlockPattern 匹配推理模型（如 DeepSeek-R1、QwQ）在输出开头给出的完整思考过程
+// 只匹配开头的思考过程，避免误删提交消息中提到的标签，如 "feat: handle <think> tag parsing"
+var reasoningBlockPattern = regexp.MustCompile(`^\s*<(think|thinking|reasoning)>(?s:.*?)</(think|thinking|reasoning)>`)
+
+// reasoningOpenTags 思考过程的起始标签
+var reasoningOpenTags = []string{"<think>", "<thinking>", "<reasoning>"}
+
+// reasoningCloseTags 思考过程的结束标签
+var reasoningCloseTags = []string{"</think>", "</thinking>", "</reasoning>"}
+
+// isReasoningModel 判断是否为 OpenAI o 系列等推理模型，这类模型不接受 max_tokens、temperature 和 top_p
+// 配置了 reasoning_model 时以配置为准
+func isReasoningModel(cfg *config.Config) bool {
+	if cfg.ReasoningModel != nil {
+		return *cfg.ReasoningModel
+	}
+	name := constants.NormalizeModelName(cfg.OpenAIModel)
+	for _, prefix := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// adaptRequestForModel 按模型调整请求参数
+// 推理模型使用 max_completion_tokens 代替 max_tokens，且不支持 n，多条候选改由并发请求生成
+func adaptRequestForModel(cfg *config.Config, request openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	if !isReasoningModel(cfg) {
+		return request
+	}
+	request.MaxCompletionTokens = request.MaxTokens
+	request.MaxTokens = 0
+	request.N = 0
+	return request
+}
+
+// stripReasoning 去掉模型输出中的思考过程，只保留最终回答
+// 只有出现在开头的起始标签才视为思考过程；部分服务商会省略起始标签（由对话模板预先写入），
+// 此时思考过程以单独成行的结束标签结尾，丢弃该行及之前的全部内容。提交消息中提到的标签保持不变
+func stripReasoning(content string) string {
+	content = trimReasoningBlocks(content)
+	if i := bareReasoningEnd(content); i >= 0 {
+		content = content[i:]
+	}
+	// 开头未闭合的思考过程（如因 max_tokens 截断）全部丢弃
+	if startsWithReasoning(content) {
+		return ""
+	}
+	return strings.TrimSpace(content)
+}
+
+// bareReasoningEnd 查找省略起始标签的思考过程的结束位置：最后一个单独成行、且之前没有对应起始标签的结束标签
+// 返回该行之后的位置，没有时返回 -1
+func bareReasoningEnd(content string) int {
+	end := -1
+	offset := 0
+	for _, line := range strings.SplitAfter(content, "\n") {
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		for k, tag := range reasoningCloseTags {
+			if trimmed == tag && !strings.Contains(content[:offset], reasoningOpenTags[k]) {
+				end = offset
+			}
+		}
+	}
+	return end
+}
+
+// trimReasoningBlocks 去掉输出开头的一个或多个完整思考过程
+func trimReasoningBlocks(text string) string {
+	for {
+		trimmed := reasoningBlockPattern.ReplaceAllString(text, "")
+		if trimmed == text {
+			return text
+		}
+		text = trimmed
+	}
+}
+
+// startsWithReasoning 判断文本（忽略开头的空白）是否以思考过程的起始标签开头
+func startsWithReasoning(text string) bool {
+	text = strings.TrimLeft(text, " \t\r\n")
+	for _, tag := range reasoningOpenTags {
+		if strings.HasPrefix(text, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleContent 返回流式输出过程中可以展示的部分：去掉开头已完成的思考过程，
+// 开头的思考过程尚未闭合时返回空字符串，并截掉末尾可能是起始标签一部分的字符
+func visibleContent(text string) string {
+	text = trimReasoningBlocks(text)
+	if startsWithReasoning(text) {
+		return ""
+	}
+	for _, tag := range reasoningOpenTags {
+		for k := len(tag) - 1; k > 0; k-- {
+			if strings.HasSuffix(text, tag[:k]) {
+				return text[:len(text)-k]
+			}
+		}
+	}
+	return text
+}
+
+// reasoningFilter 流式输出时隐藏思考过程，只实时显示最终回答
+type reasoningFilter struct {
+	out      io.Writer
+	text     strings.Builder
+	printed  string
+	thinking bool
+}
+
+// Write 追加一段增量内容，并输出新增的可见部分
+func (f *reasoningFilter) Write(delta string) {
+	f.text.WriteString(delta)
+	text := f.text.String()
+	visible := strings.TrimLeft(visibleContent(text), " \t\r\n")
+
+	if !f.thinking && len(visible) == 0 && strings.Contains(text, "<think") {
+		f.thinking = true
+		fmt.Fprintln(f.out, "（模型思考中...）")
+	}
+	// 可见内容只会在末尾增长；省略起始标签的思考过程在结束标签出现前无法识别，此时不再重复输出
+	if len(visible) > len(f.printed) && strings.HasPrefix(visible, f.printed) {
+		fmt.Fprint(f.out, visible[len(f.printed):])
+		f.printed = visible
+	}
+}
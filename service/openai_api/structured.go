@@ -0,0 +1,93 @@
+package openai_api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// StructuredCommit 结构化输出模式下模型返回的提交信息
+type StructuredCommit struct {
+	Type     string   `json:"type"`
+	Scope    string   `json:"scope"`
+	Subject  string   `json:"subject"`
+	Body     string   `json:"body"`
+	Breaking bool     `json:"breaking"`
+	Footers  []string `json:"footers"`
+}
+
+// structuredCommitSchema 结构化输出的 JSON Schema，严格模式要求列出全部字段
+var structuredCommitSchema = jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"type":     {Type: jsonschema.String, Description: "commit type, e.g. feat or fix"},
+		"scope":    {Type: jsonschema.String, Description: "optional scope, empty string if none"},
+		"subject":  {Type: jsonschema.String, Description: "short summary without the type prefix"},
+		"body":     {Type: jsonschema.String, Description: "optional longer explanation, empty string if none"},
+		"breaking": {Type: jsonschema.Boolean, Description: "true if the change breaks backward compatibility"},
+		"footers": {
+			Type:        jsonschema.Array,
+			Items:       &jsonschema.Definition{Type: jsonschema.String},
+			Description: "footer lines such as \"Refs: #123\", empty array if none",
+		},
+	},
+	Required:             []string{"type", "scope", "subject", "body", "breaking", "footers"},
+	AdditionalProperties: false,
+}
+
+// structuredOutputInstruction 结构化输出模式下追加到提示词中的格式要求
+const structuredOutputInstruction = "IMPORTANT: Respond with a single JSON object only, without markdown or code blocks, using these fields:\n" +
+	"- type: the commit type\n" +
+	"- scope: optional scope, empty string if none\n" +
+	"- subject: short summary without the type prefix\n" +
+	"- body: optional explanation of why the change was made, empty string if none\n" +
+	"- breaking: true if the change breaks backward compatibility\n" +
+	"- footers: array of footer lines such as \"Refs: #123\", empty array if none\n"
+
+// isStructuredOutput 判断是否启用结构化输出模式
+func isStructuredOutput(outputFormat string) bool {
+	return outputFormat == constants.OutputFormatJSON || outputFormat == constants.OutputFormatJSONObject
+}
+
+// responseFormat 返回请求使用的 response_format，文本模式返回 nil
+// json 模式使用 JSON Schema 约束输出；json_object 模式用于仅支持 JSON 对象的服务商
+func responseFormat(outputFormat string) *openai.ChatCompletionResponseFormat {
+	switch outputFormat {
+	case constants.OutputFormatJSON:
+		return &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "commit_message",
+				Schema: &structuredCommitSchema,
+				Strict: true,
+			},
+		}
+	case constants.OutputFormatJSONObject:
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return nil
+}
+
+// parseStructuredCommit 宽松地解析模型返回的 JSON
+// 兼容 markdown 代码块包裹、JSON 前后夹杂说明文字等情况
+func parseStructuredCommit(content string) (StructuredCommit, error) {
+	var commit StructuredCommit
+	text := strings.TrimSpace(content)
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end <= start {
+		return commit, fmt.Errorf("响应中没有 JSON 对象")
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &commit); err != nil {
+		return commit, fmt.Errorf("解析 JSON 响应失败: %w", err)
+	}
+	commit.Subject = strings.TrimSpace(commit.Subject)
+	if commit.Subject == "" {
+		return commit, fmt.Errorf("JSON 响应缺少 subject")
+	}
+	return commit, nil
+}
@@ -0,0 +1,160 @@
+package openai_api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+	"github.com/feiandxs/agcommits/utils"
+)
+
+// DefaultPromptTemplate 内置的系统提示词模板，可通过 agcommits prompt show 输出后修改为 prompt_template
+const DefaultPromptTemplate = `You are an experienced programmer who writes great commit messages.
+Generate a concise git commit message written in present tense for the code diff provided by the user, with the given specifications below:
+Message language: {{.Language}}
+{{if .BodyInstruction}}The subject line must be a maximum of {{.MaxLength}} characters.
+{{.BodyInstruction}}{{else}}Commit message must be a maximum of {{.MaxLength}} characters.
+{{end}}{{if eq .Locale "en"}}IMPORTANT: Use only lowercase letters in the commit message. No uppercase letters allowed.
+{{end}}Exclude anything unnecessary such as translation.
+{{.OutputInstruction}}{{.Guidance}}
+{{.Format}}{{if .StyleExamples}}
+
+Recent commit messages in this repository. Follow their style (casing, scope usage, wording) where it does not conflict with the rules above, but describe only the current diff:
+{{range .StyleExamples}}- {{.}}
+{{end}}{{end}}`
+
+// PromptData 渲染提示词模板时可用的数据
+type PromptData struct {
+	Diff           string             // 暂存区的 diff，可能已按上下文窗口裁剪
+	Language       string             // 提交消息语言的名称，如 English
+	Locale         string             // 提交消息语言代码，如 en、zh
+	MaxLength      int                // 提交消息标题的最大长度
+	Body           string             // 正文模式：off、auto 或 always
+	Files          []utils.StagedFile // 暂存区的文件变更列表
+	Branch         string             // 当前分支名
+	RecentCommits  []string           // 最近的非合并提交标题，从新到旧排列
+	StyleExamples  []string           // 按 history 配置选取的风格示例，未启用时为空
+	RelatedCommits []string           // 最近改动过暂存文件的提交标题，未启用 context.related_commits 时为空
+	Description    string             // 从 README 提取的项目简介，未启用 context.readme 时为空
+	Context        string             // 按 context 配置渲染的仓库上下文，已按上下文窗口裁剪，未启用时为空
+	Hint           string             // 用户通过 -m 或交互输入的改动意图说明，未提供时为空
+	CommitType     string             // 提交消息格式类型，如 conventional
+	Scope          string             // 根据变更路径推断的 scope，可能为空
+	Breaking       []string           // 检测到的可能破坏兼容性的变更
+
+	Format            string // 提交消息格式说明
+	Guidance          string // 提交类型的选择说明
+	OutputInstruction string // 与 output_format 对应的输出要求，自定义模板应保留
+	BodyInstruction   string // 与正文模式对应的要求，不生成正文时为空
+}
+
+// collectPromptData 收集渲染提示词所需的数据，获取 Git 信息失败时对应字段留空
+func collectPromptData(config *utils.Config, diff string) PromptData {
+	// 结构化输出模式下要求返回 JSON，否则只返回提交消息本身
+	outputInstruction := "Your entire response will be passed directly into git commit.\n" +
+		"IMPORTANT: Return ONLY the commit message itself. Do NOT include any markdown formatting, code blocks, or ``` symbols.\n"
+	if isStructuredOutput(config.OutputFormat) {
+		outputInstruction = structuredOutputInstruction
+	}
+
+	data := PromptData{
+		Diff:              diff,
+		Language:          constants.GetLanguagePromptName(constants.LanguageCode(config.CommitLocale)),
+		Locale:            config.CommitLocale,
+		MaxLength:         config.MaxLength,
+		Body:              config.Body,
+		BodyInstruction:   bodyInstruction(config.Body),
+		OutputInstruction: outputInstruction,
+	}
+	data.Files, _ = utils.GetStagedFiles()
+	data.Branch, _ = utils.GetCurrentBranch()
+	data.RecentCommits, _ = utils.GetRecentCommitSubjects(constants.DefaultRecentCommits)
+	return data
+}
+
+// styleExamples 按 history 配置从仓库历史中选取提交标题作为风格示例
+func styleExamples(cfg *config.Config, files []utils.StagedFile) []string {
+	if cfg.History.Examples <= 0 {
+		return nil
+	}
+	author := cfg.History.Author
+	if author == "me" {
+		author, _ = utils.GetUserEmail()
+	}
+	var paths []string
+	for _, path := range cfg.History.Paths {
+		if path != "staged" {
+			paths = append(paths, path)
+			continue
+		}
+		for _, file := range files {
+			paths = append(paths, file.Path)
+		}
+	}
+	examples, _ := utils.GetCommitSubjects(cfg.History.Examples, author, paths)
+	return examples
+}
+
+// setStyle 填入提交格式相关的数据
+func (data *PromptData) setStyle(style commitstyle.Style) {
+	data.CommitType = style.Name
+	data.Scope = style.Scope
+	data.Breaking = style.Breaking
+	data.Format = style.Format()
+	data.Guidance = style.Guidance()
+}
+
+// loadPromptTemplate 读取 prompt_template：值为模板文件路径或已存在的文件时读取文件内容，否则视为模板文本
+// 看起来像路径（见 config.IsTemplatePath）但文件不存在时返回错误，避免把拼错的路径当作提示词发送
+// 未配置时返回内置模板
+func loadPromptTemplate(value string) (string, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultPromptTemplate, nil
+	}
+	if !strings.Contains(value, "\n") {
+		path := strings.TrimSpace(value)
+		if strings.HasPrefix(path, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("读取提示词模板失败: %w", err)
+			}
+			return string(content), nil
+		}
+		if config.IsTemplatePath(value) {
+			if err == nil {
+				return "", fmt.Errorf("提示词模板 %s 是目录，不是文件", path)
+			}
+			return "", fmt.Errorf("提示词模板文件不存在: %s", path)
+		}
+	}
+	return value, nil
+}
+
+// renderPrompt 使用 text/template 渲染提示词模板
+func renderPrompt(text string, data PromptData) (string, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析提示词模板失败: %w", err)
+	}
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("渲染提示词模板失败: %w", err)
+	}
+	return builder.String(), nil
+}
+
+// templateUsesDiff 判断模板是否自行引用了 diff
+func templateUsesDiff(text string) bool {
+	return strings.Contains(text, ".Diff")
+}
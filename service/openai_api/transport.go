@@ -0,0 +1,143 @@
+package openai_api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/feiandxs/agcommits/config"
+)
+
+// bodyFieldsTransport 在对话补全请求体中写入额外字段
+// go-openai 的请求结构体会省略零值（如 temperature: 0），也不支持服务商特有的字段，因此在发送前直接修改 JSON
+type bodyFieldsTransport struct {
+	base   http.RoundTripper
+	fields map[string]interface{}
+}
+
+func (t *bodyFieldsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	body, err = mergeBodyFields(body, t.fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTripper 不应修改原请求，复制后替换请求体
+	patched := req.Clone(req.Context())
+	patched.Body = io.NopCloser(bytes.NewReader(body))
+	patched.ContentLength = int64(len(body))
+	patched.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return t.base.RoundTrip(patched)
+}
+
+// mergeBodyFields 将 fields 合并到 JSON 请求体中，同名字段以 fields 为准
+// 原有字段保持原始 JSON 不变，避免 seed 等大整数经 float64 转换后丢失精度
+func mergeBodyFields(body []byte, fields map[string]interface{}) ([]byte, error) {
+	if len(fields) == 0 {
+		return body, nil
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("extra_body 中的 %s 无法编码为 JSON: %w", key, err)
+		}
+		payload[key] = raw
+	}
+	return json.Marshal(payload)
+}
+
+// requestBodyFields 汇总需要写入请求体的采样参数和自定义字段，extra_body 最后写入，同名时覆盖采样参数
+// 采样参数显式配置时才写入，因此 temperature: 0 也能正确发送；推理模型不支持采样参数，始终不写入
+func requestBodyFields(cfg *config.Config) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if !isReasoningModel(cfg) {
+		if cfg.Temperature != nil {
+			fields["temperature"] = *cfg.Temperature
+		}
+		if cfg.TopP != nil {
+			fields["top_p"] = *cfg.TopP
+		}
+	}
+	for key, value := range cfg.ExtraBody {
+		fields[key] = value
+	}
+	return fields
+}
+
+// headerTransport 为每个请求附加自定义请求头
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	patched := req.Clone(req.Context())
+	for key, value := range t.headers {
+		patched.Header.Set(key, value)
+	}
+	return t.base.RoundTrip(patched)
+}
+
+// newNetworkTransport 按配置创建底层传输层，支持代理、自定义 CA 和跳过证书校验
+func newNetworkTransport(cfg *config.Config) (http.RoundTripper, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.HTTPProxy != "" {
+		proxyURL, err := url.Parse(cfg.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("http_proxy 格式不正确: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CABundle != "" || cfg.InsecureSkipVerify {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.CABundle != "" {
+			pool, err := loadCABundle(cfg.CABundle)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		tlsConfig.InsecureSkipVerify = cfg.InsecureSkipVerify
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// loadCABundle 在系统证书池的基础上追加 ca_bundle 中的证书
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 ca_bundle 失败: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca_bundle 中没有有效的 PEM 证书: %s", path)
+	}
+	return pool, nil
+}
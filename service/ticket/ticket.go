@@ -0,0 +1,86 @@
+// Package ticket 从分支名中提取工单编号，并按模板写入提交消息
+package ticket
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/feiandxs/agcommits/constants"
+	"github.com/feiandxs/agcommits/service/commitstyle"
+)
+
+// 未配置模板时使用的默认模板
+const (
+	DefaultFooterTemplate  = `Refs: {{join .IDs ", "}}`
+	DefaultSubjectTemplate = `{{.ID}} `
+)
+
+// Data 渲染工单模板时可用的数据
+type Data struct {
+	ID     string   // 第一个工单编号
+	IDs    []string // 全部工单编号，按在分支名中出现的顺序
+	Branch string   // 当前分支名
+}
+
+// Extract 使用正则表达式从分支名中提取工单编号，表达式含有分组时取第一个分组，否则取整个匹配；结果去重
+func Extract(branch string, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("工单编号正则表达式 %q 无效: %w", pattern, err)
+		}
+		for _, match := range re.FindAllStringSubmatch(branch, -1) {
+			id := match[0]
+			if len(match) > 1 {
+				id = match[1]
+			}
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// Apply 按 position 将工单编号写入提交消息：footer 添加为脚注，subject 加在标题描述之前
+// 提交消息中已包含全部工单编号时保持不变
+func Apply(message string, data Data, position, text string, style commitstyle.Style) (string, error) {
+	if len(data.IDs) == 0 || containsAll(message, data.IDs) {
+		return message, nil
+	}
+	if text == "" {
+		text = DefaultFooterTemplate
+		if position == constants.TicketPositionSubject {
+			text = DefaultSubjectTemplate
+		}
+	}
+
+	tmpl, err := template.New("ticket").Funcs(template.FuncMap{"join": strings.Join}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return message, fmt.Errorf("解析工单模板失败: %w", err)
+	}
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return message, fmt.Errorf("渲染工单模板失败: %w", err)
+	}
+
+	if position == constants.TicketPositionSubject {
+		return style.PrefixSubject(message, builder.String()), nil
+	}
+	return commitstyle.AppendFooter(message, strings.TrimSpace(builder.String())), nil
+}
+
+// containsAll 判断提交消息是否已包含全部工单编号
+func containsAll(message string, ids []string) bool {
+	for _, id := range ids {
+		if !strings.Contains(message, id) {
+			return false
+		}
+	}
+	return true
+}
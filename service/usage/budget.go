@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+)
+
+// ErrBudgetExceeded 用量超出预算上限
+var ErrBudgetExceeded = errors.New("已超出 AI 用量预算")
+
+// BudgetStatus 预算检查结果
+type BudgetStatus struct {
+	// Exceeded 为 true 表示至少一项上限已用尽
+	Exceeded bool
+
+	// Reasons 已超出的上限说明
+	Reasons []string
+
+	// Warnings 接近上限的警告说明
+	Warnings []string
+}
+
+// budgetLimit 单项预算上限及当前用量
+type budgetLimit struct {
+	name  string
+	limit float64
+	used  float64
+	unit  string
+}
+
+// CheckBudget 统计 apiBase 对应服务今日和本月的用量，并与预算上限比较
+func CheckBudget(budget config.Budget, apiBase string, prices map[string]config.ModelPrice, currency string) (BudgetStatus, error) {
+	var status BudgetStatus
+	if budget.DailyTokens <= 0 && budget.MonthlyTokens <= 0 && budget.DailyCost <= 0 && budget.MonthlyCost <= 0 {
+		return status, nil
+	}
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	records, err := Load(startOfMonth)
+	if err != nil {
+		return status, err
+	}
+
+	var dailyTokens, monthlyTokens int
+	var dailyCost, monthlyCost float64
+	for _, record := range records {
+		// 备用模型的用量不计入原服务的预算
+		if record.APIBase != apiBase || (budget.FallbackModel != "" && record.Model == budget.FallbackModel) {
+			continue
+		}
+		tokens := record.PromptTokens + record.CompletionTokens
+		cost, _ := Cost(record, prices)
+		monthlyTokens += tokens
+		monthlyCost += cost
+		if !record.Time.Before(startOfDay) {
+			dailyTokens += tokens
+			dailyCost += cost
+		}
+	}
+
+	if currency == "" {
+		currency = "USD"
+	}
+	limits := []budgetLimit{
+		{name: "今日 token 用量", limit: float64(budget.DailyTokens), used: float64(dailyTokens), unit: "tokens"},
+		{name: "本月 token 用量", limit: float64(budget.MonthlyTokens), used: float64(monthlyTokens), unit: "tokens"},
+		{name: "今日费用", limit: budget.DailyCost, used: dailyCost, unit: currency},
+		{name: "本月费用", limit: budget.MonthlyCost, used: monthlyCost, unit: currency},
+	}
+
+	warnRatio := budget.WarnRatio
+	if warnRatio <= 0 {
+		warnRatio = constants.DefaultBudgetWarnRatio
+	}
+	for _, l := range limits {
+		if l.limit <= 0 {
+			continue
+		}
+		detail := fmt.Sprintf("%s %s / %s %s", l.name, formatAmount(l.used), formatAmount(l.limit), l.unit)
+		switch {
+		case l.used >= l.limit:
+			status.Exceeded = true
+			status.Reasons = append(status.Reasons, detail)
+		case l.used >= l.limit*warnRatio:
+			status.Warnings = append(status.Warnings, detail)
+		}
+	}
+	return status, nil
+}
+
+// formatAmount 整数用量不显示小数，费用保留四位小数
+func formatAmount(value float64) string {
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d", int64(value))
+	}
+	return fmt.Sprintf("%.4f", value)
+}
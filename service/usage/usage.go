@@ -0,0 +1,185 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/feiandxs/agcommits/config"
+	"github.com/feiandxs/agcommits/constants"
+)
+
+// usageFileName 用量记录文件名，每行一条 JSON 记录
+const usageFileName = "usage.jsonl"
+
+// Record 单次 AI 调用的用量记录
+type Record struct {
+	Time             time.Time `json:"time"`
+	Repo             string    `json:"repo"`
+	APIBase          string    `json:"api_base"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	// Estimated 为 true 表示服务商未返回用量，token 数为本地估算值
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// Summary 按维度汇总后的用量
+type Summary struct {
+	Key              string
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	// Unpriced 为 true 表示部分记录的模型没有配置价格，费用不完整
+	Unpriced bool
+}
+
+// FilePath 返回用量记录文件的完整路径
+func FilePath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "agcommits", usageFileName), nil
+}
+
+// Append 追加一条用量记录
+func Append(record Record) error {
+	path, err := FilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Load 读取 since 之后的全部用量记录，记录文件不存在时返回空列表
+func Load(since time.Time) ([]Record, error) {
+	path, err := FilePath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		// 跳过损坏的行，不影响其余记录
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.Time.Before(since) {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Cost 按价格表计算单条记录的费用，模型未配置价格时返回 false
+func Cost(record Record, prices map[string]config.ModelPrice) (float64, bool) {
+	price, ok := prices[record.Model]
+	if !ok {
+		// 兼容去掉服务商前缀或大小写不同的写法
+		name := constants.NormalizeModelName(record.Model)
+		for model, p := range prices {
+			if constants.NormalizeModelName(model) == name {
+				price, ok = p, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+	return float64(record.PromptTokens)/1e6*price.Input + float64(record.CompletionTokens)/1e6*price.Output, true
+}
+
+// Summarize 按 model 或 repo 汇总用量，结果按费用和 token 数降序排列
+func Summarize(records []Record, by string, prices map[string]config.ModelPrice) ([]Summary, error) {
+	groups := map[string]*Summary{}
+	for _, record := range records {
+		var key string
+		switch by {
+		case "model":
+			key = record.Model
+		case "repo":
+			key = record.Repo
+		default:
+			return nil, fmt.Errorf("不支持的汇总维度: %s (可选 model 或 repo)", by)
+		}
+		summary, ok := groups[key]
+		if !ok {
+			summary = &Summary{Key: key}
+			groups[key] = summary
+		}
+		summary.Calls++
+		summary.PromptTokens += record.PromptTokens
+		summary.CompletionTokens += record.CompletionTokens
+		cost, priced := Cost(record, prices)
+		summary.Cost += cost
+		if !priced {
+			summary.Unpriced = true
+		}
+	}
+
+	result := make([]Summary, 0, len(groups))
+	for _, summary := range groups {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cost != result[j].Cost {
+			return result[i].Cost > result[j].Cost
+		}
+		return result[i].PromptTokens+result[i].CompletionTokens > result[j].PromptTokens+result[j].CompletionTokens
+	})
+	return result, nil
+}
+
+// ParseSince 解析时间范围，支持 30d、2w 等天/周单位，以及 time.ParseDuration 支持的格式（如 12h）
+func ParseSince(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if strings.HasSuffix(value, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("无效的时间范围: %s", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("无效的时间范围: %s", value)
+	}
+	return duration, nil
+}
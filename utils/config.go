@@ -24,6 +24,8 @@ func GetConfigValue(cfg *config.Config, fieldName string) string {
 		return strconv.FormatBool(cfg.AutoAdd)
 	case "auto_commit":
 		return strconv.FormatBool(cfg.AutoCommit)
+	case "request_timeout":
+		return strconv.Itoa(cfg.RequestTimeout)
 	default:
 		return ""
 	}
@@ -52,3 +54,23 @@ func PromptForValue(field config.ConfigField) (string, error) {
 	}
 	return value, nil
 }
+
+// SelectModel 以列表形式让用户选择模型，current 为当前使用的模型
+func SelectModel(models []string, current string) (string, error) {
+	prompt := &survey.Select{
+		Message:  "选择 AI 模型",
+		Options:  models,
+		PageSize: 15,
+	}
+	for _, model := range models {
+		if model == current {
+			prompt.Default = current
+			break
+		}
+	}
+	var selected string
+	if err := survey.AskOne(prompt, &selected); err != nil {
+		return "", err
+	}
+	return selected, nil
+}
@@ -39,26 +39,183 @@ func GetGitDiff() (string, error) {
 	return string(output), nil
 }
 
+// GetRepoRoot 获取当前 Git 仓库的根目录
+func GetRepoRoot() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("执行 git rev-parse 命令失败: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetCurrentBranch 获取当前分支名，处于分离头指针状态时返回 HEAD
+func GetCurrentBranch() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("执行 git rev-parse 命令失败: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetRecentCommitSubjects 获取最近 n 条非合并提交的标题，从新到旧排列
+func GetRecentCommitSubjects(n int) ([]string, error) {
+	return GetCommitSubjects(n, "", nil)
+}
+
+// GetCommitSubjects 获取最近 n 条非合并提交的标题，从新到旧排列
+// author 不为空时只包含匹配该作者的提交，paths 不为空时只包含改动了这些路径的提交
+// paths 相对于仓库根目录（与 git diff --cached 输出的路径一致），在子目录中运行时同样有效
+func GetCommitSubjects(n int, author string, paths []string) ([]string, error) {
+	args := []string{"log", "-n", fmt.Sprint(n), "--no-merges", "--format=%s"}
+	if author != "" {
+		args = append(args, "--author="+author)
+	}
+	if len(paths) > 0 {
+		args = append(args, "--")
+		for _, path := range paths {
+			// 已经带有 pathspec 魔术前缀的路径保持不变
+			if !strings.HasPrefix(path, ":") {
+				path = ":(top)" + path
+			}
+			args = append(args, path)
+		}
+	}
+	cmd := exec.Command("git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 git log 命令失败: %v", err)
+	}
+	var subjects []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+// GetUserEmail 获取 git 配置中的用户邮箱
+func GetUserEmail() (string, error) {
+	cmd := exec.Command("git", "config", "user.email")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("执行 git config 命令失败: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetHeadFileContent 获取文件在 HEAD 中的内容
+func GetHeadFileContent(path string) ([]byte, error) {
+	cmd := exec.Command("git", "show", "HEAD:"+path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 git show 命令失败: %v", err)
+	}
+	return output, nil
+}
+
+// GetStagedFileContent 获取文件在暂存区中的内容
+func GetStagedFileContent(path string) ([]byte, error) {
+	cmd := exec.Command("git", "show", ":"+path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 git show 命令失败: %v", err)
+	}
+	return output, nil
+}
+
 // ConfirmCommitMessage 显示提交消息并询问用户是否确认使用。
-func ConfirmCommitMessage(commitMsg string) bool {
-	fmt.Println("AI 生成的 Git 提交消息如下：")
+// allowHint 为 true 时用户可以输入 h 并填写提示，此时返回的提示非空，表示要求按提示重新生成
+func ConfirmCommitMessage(commitMsg string, allowHint bool) (bool, string) {
+	fmt.Println("生成的 Git 提交消息如下：")
 	fmt.Println(commitMsg)
-	fmt.Println("是否使用此提交消息进行 Git 提交？(y/n，默认: y)")
+	question := "是否使用此提交消息进行 Git 提交？(y/n，默认: y)"
+	if allowHint {
+		question = "是否使用此提交消息进行 Git 提交？(y/n，h 输入提示后重新生成，默认: y)"
+	}
+	fmt.Println(question)
 
 	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Println("读取输入时出错:", err)
-		return false
+	for {
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println("读取输入时出错:", err)
+			return false, ""
+		}
+		response = strings.TrimSpace(response)
+		if allowHint && (response == "h" || response == "H") {
+			if hint := readHint(reader); hint != "" {
+				return false, hint
+			}
+			fmt.Println(question)
+			continue
+		}
+
+		return response == "y" || response == "", ""
 	}
-	response = strings.TrimSpace(response)
+}
 
-	return response == "y" || response == ""
+// SelectCommitMessage 列出全部候选提交消息，让用户选择一条用于提交
+// 返回选中的消息；用户取消时第二个返回值为 false
+// allowHint 为 true 时用户可以输入 h 并填写提示，此时第三个返回值非空，表示要求按提示重新生成
+func SelectCommitMessage(candidates []string, allowHint bool) (string, bool, string) {
+	fmt.Println("生成的候选 Git 提交消息如下：")
+	for i, candidate := range candidates {
+		fmt.Printf("\n[%d] %s\n", i+1, strings.ReplaceAll(candidate, "\n", "\n    "))
+	}
+	question := fmt.Sprintf("请选择要使用的提交消息 (1-%d，默认: 1，n 取消)", len(candidates))
+	if allowHint {
+		question = fmt.Sprintf("请选择要使用的提交消息 (1-%d，默认: 1，h 输入提示后重新生成，n 取消)", len(candidates))
+	}
+	fmt.Println("\n" + question)
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		response, err := reader.ReadString('\n')
+		if err != nil {
+			fmt.Println("读取输入时出错:", err)
+			return "", false, ""
+		}
+		response = strings.TrimSpace(response)
+		if response == "" {
+			return candidates[0], true, ""
+		}
+		if response == "n" || response == "N" {
+			return "", false, ""
+		}
+		if allowHint && (response == "h" || response == "H") {
+			if hint := readHint(reader); hint != "" {
+				return "", false, hint
+			}
+			fmt.Println(question)
+			continue
+		}
+		var index int
+		if _, err := fmt.Sscanf(response, "%d", &index); err == nil && index >= 1 && index <= len(candidates) {
+			return candidates[index-1], true, ""
+		}
+		fmt.Printf("无效的选择，请输入 1-%d 或 n\n", len(candidates))
+	}
+}
+
+// readHint 读取用户输入的提示，描述本次改动的意图；读取失败或输入为空时返回空字符串
+func readHint(reader *bufio.Reader) string {
+	fmt.Println("请输入提示，说明本次改动的意图（直接回车放弃）：")
+	hint, err := reader.ReadString('\n')
+	if err != nil && hint == "" {
+		fmt.Println("读取输入时出错:", err)
+		return ""
+	}
+	return strings.TrimSpace(hint)
 }
 
 // PerformGitCommit 执行 Git 提交
 func PerformGitCommit(message string) error {
-	cmd := exec.Command("git", "commit", "-m", message)
+	// 通过标准输入传入提交消息，保留标题、正文和脚注之间的换行
+	cmd := exec.Command("git", "commit", "-F", "-")
+	cmd.Stdin = strings.NewReader(message)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
@@ -118,3 +275,82 @@ func ConfirmGitAdd() bool {
 
 	return response != "n" && response != "N"
 }
+
+// StagedFile 暂存区中单个文件的变更信息
+type StagedFile struct {
+	Status  string // 变更状态：A 新增、M 修改、D 删除、R 重命名、C 复制、T 类型变更
+	Path    string // 变更后的路径
+	OldPath string // 重命名或复制前的路径
+	Added   int    // 新增行数，二进制文件为 -1
+	Deleted int    // 删除行数，二进制文件为 -1
+}
+
+// GetStagedFiles 通过 git diff --cached --name-status 和 --numstat 获取暂存区的文件变更列表
+func GetStagedFiles() ([]StagedFile, error) {
+	cmd := exec.Command("git", "diff", "--cached", "--name-status", "-M", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 git diff --name-status 命令失败: %v", err)
+	}
+
+	var files []StagedFile
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i < len(fields) && fields[i] != ""; i++ {
+		file := StagedFile{Status: fields[i][:1]}
+		// 重命名和复制带有相似度（如 R100），后面依次是旧路径和新路径
+		if (file.Status == "R" || file.Status == "C") && i+2 < len(fields) {
+			file.OldPath, file.Path = fields[i+1], fields[i+2]
+			i += 2
+		} else if i+1 < len(fields) {
+			file.Path = fields[i+1]
+			i++
+		}
+		files = append(files, file)
+	}
+
+	stats, err := stagedNumstat()
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if stat, ok := stats[files[i].Path]; ok {
+			files[i].Added, files[i].Deleted = stat[0], stat[1]
+		}
+	}
+	return files, nil
+}
+
+// stagedNumstat 返回暂存区每个文件的新增和删除行数，键为变更后的路径
+func stagedNumstat() (map[string][2]int, error) {
+	cmd := exec.Command("git", "diff", "--cached", "--numstat", "-M", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("执行 git diff --numstat 命令失败: %v", err)
+	}
+
+	stats := map[string][2]int{}
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		path := parts[2]
+		// 重命名时路径为空，后面依次是旧路径和新路径
+		if path == "" && i+2 < len(fields) {
+			path = fields[i+2]
+			i += 2
+		}
+		stats[path] = [2]int{parseNumstat(parts[0]), parseNumstat(parts[1])}
+	}
+	return stats, nil
+}
+
+// parseNumstat 解析 numstat 中的行数，二进制文件显示为 "-"，返回 -1
+func parseNumstat(value string) int {
+	var n int
+	if _, err := fmt.Sscanf(value, "%d", &n); err != nil {
+		return -1
+	}
+	return n
+}